@@ -1,69 +1,110 @@
 package handler
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
 	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
 	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/FelipeMCassiano/urubu_bank/internal/metrics"
 	"github.com/go-playground/validator/v10"
-	"github.com/go-redis/redis"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrInvalidJson  = errors.New("invalid json")
-	ErrNotFound     = errors.New("client not found")
-	InvalidToDToErr = errors.New("invalid request")
-	LimitErr        = errors.New("limit error")
-	BalanceErr      = errors.New("value bigger than balance")
+	ErrInvalidJson        = apperr.New("invalid_json", http.StatusBadRequest, "invalid json")
+	InvalidParamErr       = apperr.New("invalid_parameter", http.StatusBadRequest, "invalid path parameter")
+	UnauthorizedErr       = apperr.New("unauthorized", http.StatusUnauthorized, "unauthorized")
+	InvalidSessionErr     = apperr.New("invalid_session", http.StatusUnauthorized, "invalid session token")
+	InvalidCredentialsErr = apperr.New("invalid_credentials", http.StatusUnauthorized, "invalid login or password")
+	InvalidFromErr        = apperr.New("invalid_from", http.StatusBadRequest, "invalid from, expected RFC3339 or YYYY-MM-DD")
+	InvalidToErr          = apperr.New("invalid_to", http.StatusBadRequest, "invalid to, expected RFC3339 or YYYY-MM-DD")
+	InvalidLimitErr       = apperr.New("invalid_limit", http.StatusBadRequest, "invalid limit")
+	UnsupportedFormatErr  = apperr.New("unsupported_format", http.StatusNotAcceptable, "unsupported format, expected json, csv or ofx")
+	AuthModeDisabledErr   = apperr.New("auth_mode_disabled", http.StatusBadRequest, "authentication mode not enabled")
 )
 
 type TransactionRequestDebit struct {
 	Value         int    `json:"value" validate:"required,gt=0"`
 	Kind          string `json:"kind" validate:"required,oneof=debit"`
-	Description   string `json:"description" validate:"required, min=1, max=10"`
+	Description   string `json:"description" validate:"required,min=1,max=10"`
 	PayeeUrubuKey string `json:"payeeurubukey" validate:"required"`
 }
 type TransactionRequestCredit struct {
 	Value       int    `json:"value" validate:"required,gt=0"`
 	Kind        string `json:"kind" validate:"required,oneof=credit"`
-	Description string `json:"description" validate:"required, min=1, max=10"`
+	Description string `json:"description" validate:"required,min=1,max=10"`
 }
 type UserLoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required, min=1, max=10"`
+	Login    string `json:"login" validate:"required"`
+	Password string `json:"password" validate:"required,min=1,max=10"`
+	Mode     string `json:"mode" validate:"omitempty,oneof=cookie bearer"`
 }
 type UrubuTradingRequest struct {
-	Username string `json:"username" validate:"required"`
+	Login    string `json:"login" validate:"required"`
 	Password string `json:"password" validate:"required"`
 	Value    int    `json:"value" validate:"required,gt=0"`
 }
 
 type BankController struct {
 	bankService bank.Service
+	sessions    auth.Sessions
+	tokens      auth.Tokens
+	metrics     *metrics.Metrics
+	logger      *slog.Logger
+	sessionTTL  time.Duration
+	cookies     bool
+	dummyHash   []byte
 }
 
-type ErrorResponse struct {
-	Error       bool
-	FailedField string
-	Tag         string
-	Value       interface{}
-}
+// NewBank takes nil tokens when bearer authentication is disabled.
+func NewBank(s bank.Service, sessions auth.Sessions, tokens auth.Tokens, cfg config.Config, m *metrics.Metrics, logger *slog.Logger) *BankController {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("urubu"), cfg.Auth.BcryptCost)
 
-func NewBank(s bank.Service) *BankController {
 	return &BankController{
 		bankService: s,
+		sessions:    sessions,
+		tokens:      tokens,
+		metrics:     m,
+		logger:      logger,
+		sessionTTL:  cfg.Auth.SessionTTL,
+		cookies:     cfg.Auth.CookieEnabled(),
+		dummyHash:   dummyHash,
+	}
+}
+
+func operationOutcome(err error) string {
+	switch {
+	case errors.Is(err, bank.LimitErr), errors.Is(err, bank.BalanceErr):
+		return metrics.OutcomeLimit
+	case errors.Is(err, bank.ErrNotFound):
+		return metrics.OutcomeNotFound
 	}
+	return metrics.OutcomeError
 }
 
 var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
 }
 
 func validateStruct(data interface{}) error {
@@ -74,70 +115,103 @@ func validateStruct(data interface{}) error {
 	return nil
 }
 
-const sessionName = "session-name"
+const (
+	sessionName       = "session-name"
+	sessionCostumerID = "costumer_id"
+	sessionToken      = "session_token"
+	sessionID         = "session_id"
+	accessToken       = "access_token"
+)
 
 func (b *BankController) UrubuTrading() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		request := UrubuTradingRequest{}
 
-		if err := ctx.BodyParser(request); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+		if err := ctx.BodyParser(&request); err != nil {
+			return ErrInvalidJson.Wrap(err)
 		}
 
 		if err := validateStruct(request); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
 
-		user, err := b.bankService.GetUsernameAndPassword(ctx.Context(), request.Username)
+		user, err := b.checkCredentials(ctx.UserContext(), request.Login, request.Password)
 		if err != nil {
-			return ctx.Status(fiber.StatusNotFound).SendString(err.Error())
+			return err
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
-			return ctx.Status(fiber.StatusUnauthorized).SendString("Password not correct")
+		response, err := b.bankService.UrubuTrading(ctx.UserContext(), user, request.Value)
+		if err != nil {
+			return err
 		}
 
-		result := make(chan domain.ValueTraded, 1)
-		errChan := make(chan error, 1)
+		if response == 0 {
+			return ctx.SendString("Thank you for money dumbass")
+		}
 
-		go b.bankService.UrubuTrading(ctx.Context(), user, request.Value, result, errChan)
+		return ctx.JSON(fiber.Map{
+			"Congratulations,you've earned": response,
+		})
+	}
+}
 
-		select {
-		case response := <-result:
-			if response == 0 {
-				return ctx.SendString("Thank you for money dumbass")
+func (b *BankController) IsAuthenticated() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if header := ctx.Get(fiber.HeaderAuthorization); header != "" && b.tokens != nil {
+			scheme, raw, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") {
+				return UnauthorizedErr
 			}
 
-			return ctx.JSON(fiber.Map{
-				"Congratulations,you've earned": response,
-			})
+			access, err := b.tokens.Verify(ctx.UserContext(), raw)
+			if err != nil {
+				return err
+			}
+
+			ctx.Locals(sessionCostumerID, access.CostumerID)
+			ctx.Locals(sessionToken, access.ID)
+			ctx.Locals(accessToken, access)
+
+			return ctx.Next()
+		}
 
-		case err := <-errChan:
-			if err == BalanceErr {
-				return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+		token := utils.CopyString(ctx.Cookies(sessionName))
+		if token == "" || !b.cookies {
+			return UnauthorizedErr
+		}
+
+		session, err := b.sessions.Authenticate(ctx.UserContext(), token)
+		if err != nil {
+			if errors.Is(err, auth.SessionNotFoundErr) {
+				return InvalidSessionErr
 			}
-			return ctx.Status(fiber.StatusInternalServerError).JSON(err.Error())
+			return err
 		}
+
+		b.setSessionCookie(ctx, token)
+
+		ctx.Locals(sessionCostumerID, session.CostumerID)
+		ctx.Locals(sessionToken, token)
+		ctx.Locals(sessionID, session.ID)
+
+		return ctx.Next()
 	}
 }
 
-func (b *BankController) IsAuthenticated() fiber.Handler {
+func (b *BankController) IsAuthorized() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		token, err := b.bankService.RetrieveCookies(sessionName)
+		id, err := ctx.ParamsInt("id")
 		if err != nil {
-			if err == redis.Nil {
-				return ctx.Status(fiber.StatusUnauthorized).SendString("Unauthorized")
-			}
-			return ctx.Status(fiber.StatusInternalServerError).JSON(err.Error())
+			return InvalidParamErr.Wrap(err)
 		}
 
-		fmt.Printf("token: %v\n", token)
-		if token == "" {
-			return ctx.Status(fiber.StatusUnauthorized).SendString("Unauthorized")
+		costumerID, ok := ctx.Locals(sessionCostumerID).(int)
+		if !ok {
+			return UnauthorizedErr
 		}
 
-		if err := b.bankService.VerifyIfTokenExists(token); err != nil {
-			return ctx.Status(fiber.StatusUnauthorized).SendString("Invalid Session token")
+		if err := b.bankService.AuthorizeCostumer(ctx.UserContext(), costumerID, id); err != nil {
+			return err
 		}
 
 		return ctx.Next()
@@ -148,46 +222,98 @@ func (b *BankController) Login() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		userLogin := UserLoginRequest{}
 
-		if err := ctx.BodyParser(userLogin); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+		if err := ctx.BodyParser(&userLogin); err != nil {
+			return ErrInvalidJson.Wrap(err)
 		}
 
 		if err := validateStruct(userLogin); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
-		user, err := b.bankService.GetUsernameAndPassword(ctx.Context(), userLogin.Username)
+		user, err := b.checkCredentials(ctx.UserContext(), userLogin.Login, userLogin.Password)
 		if err != nil {
-			return ctx.Status(fiber.StatusNotFound).SendString("user not found")
+			return err
+		}
+
+		bearer := userLogin.Mode == "bearer" || userLogin.Mode == "" && !b.cookies
+		if bearer && b.tokens == nil || !bearer && !b.cookies {
+			return AuthModeDisabledErr
+		}
+
+		if bearer {
+			pair, err := b.tokens.Issue(ctx.UserContext(), user.ID)
+			if err != nil {
+				return err
+			}
+
+			return ctx.Status(fiber.StatusOK).JSON(pair)
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(userLogin.Password)); err != nil {
-			return ctx.Status(fiber.StatusUnauthorized).SendString("Invalid password")
+		if previous := ctx.Cookies(sessionName); previous != "" {
+			if err := b.sessions.End(ctx.UserContext(), previous); err != nil {
+				return err
+			}
 		}
 
-		token, err := b.bankService.CreateSessionToken(sessionName)
+		// Fiber reuses request buffers, so copy what outlives the request.
+		ip, userAgent := utils.CopyString(ctx.IP()), utils.CopyString(ctx.Get(fiber.HeaderUserAgent))
+
+		token, _, err := b.sessions.Start(ctx.UserContext(), user.ID, ip, userAgent)
 		if err != nil {
 			return err
 		}
-		log.Println(sessionName)
-
-		log.Println(token)
 
-		ctx.Cookie(&fiber.Cookie{
-			Name:     sessionName,
-			Value:    token,
-			Expires:  time.Now().Add(24 * time.Hour),
-			HTTPOnly: true,
-		})
+		b.setSessionCookie(ctx, token)
 
 		return ctx.SendString("Login successful")
 	}
 }
 
+// checkCredentials answers an unknown login exactly like a wrong password,
+// hashing against a dummy so neither the status nor the timing tells
+// whether a CPF or email has an account.
+func (b *BankController) checkCredentials(ctx context.Context, login, password string) (domain.User, error) {
+	user, err := b.bankService.GetCredentials(ctx, login)
+	if errors.Is(err, bank.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(b.dummyHash, []byte(password))
+		return domain.User{}, InvalidCredentialsErr
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return domain.User{}, InvalidCredentialsErr
+	}
+
+	return user, nil
+}
+
+// setSessionCookie (re)issues the cookie so its expiry follows the session's
+// sliding ttl.
+func (b *BankController) setSessionCookie(ctx *fiber.Ctx, token string) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     sessionName,
+		Value:    token,
+		Expires:  time.Now().Add(b.sessionTTL),
+		HTTPOnly: true,
+	})
+}
+
 func (b *BankController) Logout() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		err := b.bankService.DeleteSessionToken(sessionName)
+		if access, ok := ctx.Locals(accessToken).(auth.Access); ok {
+			if err := b.tokens.Revoke(ctx.UserContext(), access); err != nil {
+				return err
+			}
+
+			return ctx.SendString("Logout successful")
+		}
+
+		token, _ := ctx.Locals(sessionToken).(string)
+
+		err := b.sessions.End(ctx.UserContext(), token)
 		if err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).JSON(err.Error())
+			return err
 		}
 
 		ctx.ClearCookie(sessionName)
@@ -198,20 +324,20 @@ func (b *BankController) Logout() fiber.Handler {
 func (b *BankController) DeposityMoney() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		input := &TransactionRequestCredit{}
-		stdctx := ctx.Context()
+		stdctx := ctx.UserContext()
 
 		if err := ctx.BodyParser(input); err != nil {
-			return ctx.Status(fiber.StatusBadRequest).JSON(ErrInvalidJson.Error())
+			return ErrInvalidJson.Wrap(err)
 		}
 
 		if err := validateStruct(input); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
 
 		id, _ := ctx.ParamsInt("id")
 		_, err := b.bankService.VerifyIfCostumerExists(stdctx, id)
 		if err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
 
 		newtransaction := domain.TransactionCredit{
@@ -222,41 +348,37 @@ func (b *BankController) DeposityMoney() fiber.Handler {
 			Completed_at: time.Now(),
 		}
 
-		result := make(chan domain.TransactionResponseCredit, 1)
-		errChan := make(chan error, 1)
-
-		go b.bankService.DeposityMoney(stdctx, newtransaction, result, errChan)
-
-		select {
-		case response := <-result:
-
-			return ctx.Status(fiber.StatusOK).JSON(response)
-		case err := <-errChan:
-
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+		response, err := b.bankService.DeposityMoney(stdctx, newtransaction)
+		if err != nil {
+			b.metrics.RecordOperation(metrics.OperationDeposit, operationOutcome(err), input.Value)
+			return err
 		}
+
+		b.metrics.RecordOperation(metrics.OperationDeposit, metrics.OutcomeSuccess, input.Value)
+		return ctx.Status(fiber.StatusOK).JSON(response)
 	}
 }
 
 func (b *BankController) CreateTransaction() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		input := &TransactionRequestDebit{}
-		stdctx := ctx.Context()
+		stdctx := ctx.UserContext()
 
 		if err := ctx.BodyParser(input); err != nil {
-			return ctx.Status(fiber.StatusBadRequest).JSON(ErrInvalidJson.Error())
+			return ErrInvalidJson.Wrap(err)
 		}
 
 		if err := validateStruct(input); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
 
-		log.Println(input.Description)
-
 		id, _ := ctx.ParamsInt("id")
+		b.logger.DebugContext(stdctx, "transfer requested", "client_id", id, "value", input.Value, "payeeurubukey", input.PayeeUrubuKey)
+
 		payor, err := b.bankService.VerifyIfCostumerExists(stdctx, id)
 		if err != nil {
-			return ctx.Status(fiber.StatusNotFound).JSON(ErrNotFound.Error())
+			b.metrics.RecordOperation(metrics.OperationTransfer, operationOutcome(err), input.Value)
+			return err
 		}
 
 		newtransaction := domain.TransactionDebit{
@@ -268,39 +390,45 @@ func (b *BankController) CreateTransaction() fiber.Handler {
 			PayeeUrubuKey: input.PayeeUrubuKey,
 			Completed_at:  time.Now(),
 		}
-		result := make(chan domain.TransactionResponseDebit, 1)
-		errChan := make(chan error, 1)
 
-		go b.bankService.CreateTransaction(stdctx, newtransaction, result, errChan)
+		response, err := b.bankService.CreateTransaction(stdctx, newtransaction)
+		if err != nil {
+			b.metrics.RecordOperation(metrics.OperationTransfer, operationOutcome(err), input.Value)
+			return err
+		}
 
-		select {
-		case response := <-result:
-			return ctx.Status(fiber.StatusCreated).JSON(response)
-		case err := <-errChan:
-			if err.Error() == ErrNotFound.Error() {
-				return ctx.Status(fiber.StatusNotFound).JSON(ErrNotFound.Error())
-			}
-			if err.Error() == LimitErr.Error() {
-				return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
-			}
-			return ctx.Status(fiber.StatusInternalServerError).JSON(err.Error())
+		b.metrics.RecordOperation(metrics.OperationTransfer, metrics.OutcomeSuccess, input.Value)
+		return ctx.Status(fiber.StatusCreated).JSON(response)
+	}
+}
 
+func (b *BankController) ReverseTransaction() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+		txid, err := ctx.ParamsInt("txid")
+		if err != nil {
+			return InvalidParamErr.Wrap(err)
 		}
+
+		reversal, err := b.bankService.ReverseTransaction(ctx.UserContext(), id, txid)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(reversal)
 	}
 }
 
 func (b *BankController) SearchCostumerByName() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		name := ctx.Query("name")
-		stdctx := ctx.Context()
+		stdctx := ctx.UserContext()
 
-		log.Printf("catch a query with name %s", name)
+		b.logger.DebugContext(stdctx, "searching costumers", "name", name)
 
 		respose, err := b.bankService.SearchClientByName(stdctx, name)
 		if err != nil {
-			return ctx.Status(fiber.StatusNotFound).JSON(ErrNotFound.Error())
-
-			// return ctx.Status(fiber.StatusNotFound).JSON(err.Error())
+			return err
 		}
 
 		return ctx.Status(fiber.StatusOK).JSON(respose)
@@ -310,23 +438,23 @@ func (b *BankController) SearchCostumerByName() fiber.Handler {
 func (b *BankController) CreateNewAccount() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		newcostumer := domain.CreateCostumer{}
-		stdctx := ctx.Context()
+		stdctx := ctx.UserContext()
 
 		if err := ctx.BodyParser(&newcostumer); err != nil {
-			return ctx.Status(fiber.StatusBadRequest).JSON(err.Error())
+			return ErrInvalidJson.Wrap(err)
 		}
 
 		if err := validateStruct(newcostumer); err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
 
 		createdCostumer, err := b.bankService.CreateNewAccount(stdctx, newcostumer)
 		if err != nil {
-			return ctx.Status(fiber.StatusBadRequest).JSON(err.Error())
+			return err
 		}
 		urubukey, err := b.bankService.GenerateUrubukey(stdctx, createdCostumer.ID)
 		if err != nil {
-			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(err.Error())
+			return err
 		}
 		createdCostumer.UrubuKey = urubukey
 
@@ -338,17 +466,81 @@ func (b *BankController) GetBankStatement() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		id, err := ctx.ParamsInt("id")
 		if err != nil {
-			return ctx.Status(fiber.StatusBadRequest).JSON(err.Error())
+			return InvalidParamErr.Wrap(err)
 		}
 
-		stdctx := ctx.Context()
+		filter, err := parseStatementFilter(ctx)
+		if err != nil {
+			return err
+		}
+
+		format := statementFormat(ctx)
+		switch format {
+		case "":
+			return UnsupportedFormatErr
+		case formatCSV, formatOFX:
+			return b.exportBankStatement(ctx, id, filter, format)
+		}
+
+		stdctx := ctx.UserContext()
 
-		bankstatement, err := b.bankService.GetBankStatement(stdctx, id)
+		bankstatement, err := b.bankService.GetBankStatement(stdctx, id, filter)
 		if err != nil {
-			log.Println(err.Error())
-			return ctx.Status(fiber.StatusNoContent).JSON(err.Error())
+			return err
 		}
 
 		return ctx.Status(fiber.StatusOK).JSON(bankstatement)
 	}
 }
+
+func parseStatementFilter(ctx *fiber.Ctx) (domain.StatementFilter, error) {
+	filter := domain.StatementFilter{}
+
+	if from := ctx.Query("from"); from != "" {
+		parsed, _, err := parseStatementTime(from)
+		if err != nil {
+			return domain.StatementFilter{}, InvalidFromErr
+		}
+		filter.From = parsed
+	}
+
+	if to := ctx.Query("to"); to != "" {
+		parsed, dateOnly, err := parseStatementTime(to)
+		if err != nil {
+			return domain.StatementFilter{}, InvalidToErr
+		}
+		// to is exclusive, so a bare date stops at the start of the next day
+		// and still covers the one asked for.
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		filter.To = parsed
+	}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > bank.MaxStatementLimit {
+			return domain.StatementFilter{}, InvalidLimitErr
+		}
+		filter.Limit = parsed
+	}
+
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		parsed, err := bank.DecodeStatementCursor(cursor)
+		if err != nil {
+			return domain.StatementFilter{}, err
+		}
+		filter.Cursor = &parsed
+	}
+
+	return filter, nil
+}
+
+func parseStatementTime(value string) (time.Time, bool, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.UTC(), false, nil
+	}
+
+	parsed, err := time.Parse(time.DateOnly, value)
+	return parsed, true, err
+}
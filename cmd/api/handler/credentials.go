@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UpdateCredentialsRequest struct {
+	Password string `json:"password" validate:"required"`
+	Document string `json:"document" validate:"required_without=Email"`
+	Email    string `json:"email" validate:"omitempty,email"`
+}
+
+// UpdateCredentials lets the account holder add the CPF and email they log
+// in with. The password is asked again, so a stolen session alone cannot
+// take over the account's logins.
+func (b *BankController) UpdateCredentials() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		input := UpdateCredentialsRequest{}
+
+		if err := ctx.BodyParser(&input); err != nil {
+			return ErrInvalidJson.Wrap(err)
+		}
+
+		if err := validateStruct(input); err != nil {
+			return err
+		}
+
+		id, _ := ctx.ParamsInt("id")
+
+		if _, err := b.checkCredentials(ctx.UserContext(), strconv.Itoa(id), input.Password); err != nil {
+			return err
+		}
+
+		updated, err := b.bankService.UpdateCredentials(ctx.UserContext(), id, domain.Credentials{Document: input.Document, Email: input.Email})
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(updated)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type HealthController struct {
+	checker *health.Checker
+}
+
+func NewHealth(c *health.Checker) *HealthController {
+	return &HealthController{
+		checker: c,
+	}
+}
+
+func (h *HealthController) Liveness() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+	}
+}
+
+func (h *HealthController) Readiness() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		stdctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+		defer cancel()
+
+		failures := h.checker.Ready(stdctx)
+		if len(failures) == 0 {
+			return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ready"})
+		}
+
+		checks := fiber.Map{}
+		for name, err := range failures {
+			checks[name] = err.Error()
+		}
+
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "unavailable",
+			"checks": checks,
+		})
+	}
+}
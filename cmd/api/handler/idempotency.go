@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+var (
+	IdempotencyConflictErr   = apperr.New("idempotency_key_conflict", http.StatusConflict, "idempotency key already used with a different request")
+	IdempotencyInProgressErr = apperr.New("idempotency_in_progress", http.StatusConflict, "request with this idempotency key is still in progress")
+)
+
+func (b *BankController) Idempotent() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(idempotencyHeader)
+		if key == "" {
+			return ctx.Next()
+		}
+
+		costumerID, _ := ctx.Locals(sessionCostumerID).(int)
+		key = fmt.Sprintf("%d:%s:%s", costumerID, ctx.Route().Path, key)
+
+		sum := sha256.Sum256(append([]byte(ctx.OriginalURL()+"\n"), ctx.Body()...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		reserved, err := b.bankService.ReserveIdempotencyKey(ctx.UserContext(), key, requestHash)
+		if err != nil {
+			return err
+		}
+
+		if !reserved {
+			stored, err := b.bankService.GetIdempotencyKey(ctx.UserContext(), key)
+			if err != nil {
+				if errors.Is(err, cache.ErrMiss) {
+					return IdempotencyInProgressErr
+				}
+				return err
+			}
+
+			if stored.RequestHash != requestHash {
+				return IdempotencyConflictErr
+			}
+			if !stored.Completed {
+				return IdempotencyInProgressErr
+			}
+
+			ctx.Set(fiber.HeaderContentType, stored.ContentType)
+			return ctx.Status(stored.Status).Send(stored.Body)
+		}
+
+		// Render handler errors here so client errors are replayed like any
+		// other completed response.
+		if err := ctx.Next(); err != nil {
+			if err := ctx.App().Config().ErrorHandler(ctx, err); err != nil {
+				_ = b.bankService.DeleteIdempotencyKey(ctx.UserContext(), key)
+				return err
+			}
+		}
+
+		status := ctx.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			return b.bankService.DeleteIdempotencyKey(ctx.UserContext(), key)
+		}
+
+		response := domain.IdempotentResponse{
+			RequestHash: requestHash,
+			Completed:   true,
+			Status:      status,
+			ContentType: string(ctx.Response().Header.ContentType()),
+			Body:        append([]byte(nil), ctx.Response().Body()...),
+		}
+
+		return b.bankService.SaveIdempotencyKey(ctx.UserContext(), key, response)
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/gofiber/fiber/v2"
+)
+
+var TooManyRequestsErr = apperr.New("too_many_requests", http.StatusTooManyRequests, "too many requests")
+
+func (b *BankController) RateLimit(name string, limit int, window time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		// Keyed by costumer rather than session or token, so logging in
+		// again or refreshing does not start a fresh count.
+		caller := "ip:" + ctx.IP()
+		if costumerID, ok := ctx.Locals(sessionCostumerID).(int); ok {
+			caller = "costumer:" + strconv.Itoa(costumerID)
+		}
+
+		allowed, retryAfter, err := b.bankService.AllowRequest(ctx.UserContext(), fmt.Sprintf("%s:%s", name, caller), limit, window)
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			return TooManyRequestsErr
+		}
+
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	RunAtInPastErr      = apperr.New("invalid_run_at", http.StatusUnprocessableEntity, "run_at must be in the future")
+	EndAtBeforeRunAtErr = apperr.New("invalid_end_at", http.StatusUnprocessableEntity, "end_at must not be before run_at")
+)
+
+type ScheduledTransferRequest struct {
+	Value         int        `json:"value" validate:"required,gt=0"`
+	Description   string     `json:"description" validate:"required,min=1,max=10"`
+	PayeeUrubuKey string     `json:"payeeurubukey" validate:"required"`
+	RunAt         time.Time  `json:"run_at" validate:"required"`
+	Recurrence    string     `json:"recurrence" validate:"required,oneof=once daily weekly monthly"`
+	EndAt         *time.Time `json:"end_at"`
+}
+
+// toScheduledTransfer stores times in UTC: the columns carry no offset and
+// the scheduler compares them with its own clock in UTC.
+func (r ScheduledTransferRequest) toScheduledTransfer(clientID int) (domain.ScheduledTransfer, error) {
+	if !r.RunAt.After(time.Now()) {
+		return domain.ScheduledTransfer{}, RunAtInPastErr
+	}
+	if r.EndAt != nil && r.EndAt.Before(r.RunAt) {
+		return domain.ScheduledTransfer{}, EndAtBeforeRunAtErr
+	}
+
+	var endAt *time.Time
+	if r.EndAt != nil {
+		utc := r.EndAt.UTC()
+		endAt = &utc
+	}
+
+	return domain.ScheduledTransfer{
+		Client_Id:     clientID,
+		Value:         r.Value,
+		Description:   r.Description,
+		PayeeUrubuKey: r.PayeeUrubuKey,
+		NextRunAt:     r.RunAt.UTC(),
+		Recurrence:    r.Recurrence,
+		EndAt:         endAt,
+	}, nil
+}
+
+func (b *BankController) CreateScheduledTransfer() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		input := ScheduledTransferRequest{}
+
+		if err := ctx.BodyParser(&input); err != nil {
+			return ErrInvalidJson.Wrap(err)
+		}
+
+		if err := validateStruct(input); err != nil {
+			return err
+		}
+
+		id, _ := ctx.ParamsInt("id")
+		scheduled, err := input.toScheduledTransfer(id)
+		if err != nil {
+			return err
+		}
+
+		created, err := b.bankService.CreateScheduledTransfer(ctx.UserContext(), scheduled)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(created)
+	}
+}
+
+func (b *BankController) ListScheduledTransfers() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+
+		scheduled, err := b.bankService.ListScheduledTransfers(ctx.UserContext(), id)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(scheduled)
+	}
+}
+
+func (b *BankController) GetScheduledTransfer() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+		scheduledID, err := ctx.ParamsInt("scheduledid")
+		if err != nil {
+			return InvalidParamErr.Wrap(err)
+		}
+
+		scheduled, err := b.bankService.GetScheduledTransfer(ctx.UserContext(), id, scheduledID)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(scheduled)
+	}
+}
+
+func (b *BankController) UpdateScheduledTransfer() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		input := ScheduledTransferRequest{}
+
+		if err := ctx.BodyParser(&input); err != nil {
+			return ErrInvalidJson.Wrap(err)
+		}
+
+		if err := validateStruct(input); err != nil {
+			return err
+		}
+
+		id, _ := ctx.ParamsInt("id")
+		scheduledID, err := ctx.ParamsInt("scheduledid")
+		if err != nil {
+			return InvalidParamErr.Wrap(err)
+		}
+
+		scheduled, err := input.toScheduledTransfer(id)
+		if err != nil {
+			return err
+		}
+		scheduled.ID = scheduledID
+
+		updated, err := b.bankService.UpdateScheduledTransfer(ctx.UserContext(), scheduled)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(updated)
+	}
+}
+
+func (b *BankController) CancelScheduledTransfer() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+		scheduledID, err := ctx.ParamsInt("scheduledid")
+		if err != nil {
+			return InvalidParamErr.Wrap(err)
+		}
+
+		if err := b.bankService.CancelScheduledTransfer(ctx.UserContext(), id, scheduledID); err != nil {
+			return err
+		}
+
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SessionResponse struct {
+	auth.Session
+	Current bool `json:"current"`
+}
+
+func (b *BankController) ListSessions() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+		current, _ := ctx.Locals(sessionID).(string)
+
+		sessions, err := b.sessions.List(ctx.UserContext(), id)
+		if err != nil {
+			return err
+		}
+
+		response := make([]SessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			response = append(response, SessionResponse{Session: session, Current: session.ID == current})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+func (b *BankController) RevokeSession() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+		revoked := ctx.Params("sessionid")
+
+		if err := b.sessions.Revoke(ctx.UserContext(), id, revoked); err != nil {
+			return err
+		}
+
+		if current, _ := ctx.Locals(sessionID).(string); revoked == current {
+			ctx.ClearCookie(sessionName)
+		}
+
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+}
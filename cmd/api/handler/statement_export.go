@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatOFX  = "ofx"
+
+	mimeCSV = "text/csv"
+	mimeOFX = "application/x-ofx"
+
+	ofxTime = "20060102150405"
+)
+
+func statementFormat(ctx *fiber.Ctx) string {
+	switch ctx.Query("format") {
+	case formatCSV:
+		return formatCSV
+	case formatOFX:
+		return formatOFX
+	case formatJSON:
+		return formatJSON
+	case "":
+	default:
+		return ""
+	}
+
+	switch ctx.Accepts(fiber.MIMEApplicationJSON, mimeCSV, mimeOFX) {
+	case mimeCSV:
+		return formatCSV
+	case mimeOFX:
+		return formatOFX
+	default:
+		return formatJSON
+	}
+}
+
+func (b *BankController) exportBankStatement(ctx *fiber.Ctx, id int, filter domain.StatementFilter, format string) error {
+	stream, err := b.bankService.StreamBankStatement(ctx.UserContext(), id, filter)
+	if err != nil {
+		return err
+	}
+
+	write := writeStatementCSV
+	contentType := mimeCSV + "; charset=utf-8"
+
+	if format == formatOFX {
+		write = func(w *bufio.Writer, stream bank.StatementStream) error {
+			return writeStatementOFX(w, stream, id, filter)
+		}
+		contentType = mimeOFX
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"statement-%d.%s\"", id, format))
+	stdctx := ctx.UserContext()
+	ctx.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer stream.Close()
+
+		if err := write(w, stream); err != nil {
+			b.logger.ErrorContext(stdctx, "writing bank statement", "client_id", id, "format", format, "error", err)
+		}
+	})
+
+	return nil
+}
+
+func writeStatementCSV(w *bufio.Writer, stream bank.StatementStream) error {
+	out := csv.NewWriter(w)
+
+	if err := out.Write([]string{"id", "completed_at", "kind", "value", "description", "payor", "payee"}); err != nil {
+		return err
+	}
+
+	for stream.Next() {
+		t, err := stream.Transaction()
+		if err != nil {
+			return err
+		}
+
+		err = out.Write([]string{
+			strconv.Itoa(t.ID),
+			t.Completed_at.UTC().Format(time.RFC3339),
+			t.Kind,
+			strconv.Itoa(t.Value),
+			csvCell(t.Description),
+			csvCell(t.Payor),
+			csvCell(t.Payee),
+		})
+		if err != nil {
+			return err
+		}
+
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+
+	return stream.Err()
+}
+
+// csvCell quotes text typed by costumers that a spreadsheet would otherwise
+// evaluate as a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func writeStatementOFX(w *bufio.Writer, stream bank.StatementStream, id int, filter domain.StatementFilter) error {
+	now := time.Now().UTC()
+
+	start, end := filter.From, filter.To
+	if start.IsZero() {
+		start = time.Unix(0, 0)
+	}
+	if end.IsZero() {
+		end = now
+	}
+
+	fmt.Fprint(w, xml.Header)
+	fmt.Fprint(w, `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+"\n")
+	fmt.Fprint(w, "<OFX>\n")
+	fmt.Fprintf(w, "<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>POR</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", now.Format(ofxTime))
+	fmt.Fprint(w, "<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><STMTRS>\n")
+	fmt.Fprint(w, "<CURDEF>BRL</CURDEF>\n")
+	fmt.Fprintf(w, "<BANKACCTFROM><BANKID>URUBU</BANKID><ACCTID>%d</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", id)
+	fmt.Fprintf(w, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", start.UTC().Format(ofxTime), end.UTC().Format(ofxTime))
+
+	for stream.Next() {
+		t, err := stream.Transaction()
+		if err != nil {
+			return err
+		}
+
+		trnType, amount, name := "CREDIT", t.Value, t.Payor
+		if t.Kind == "d" {
+			trnType, amount, name = "DEBIT", -t.Value, t.Payee
+		}
+
+		fmt.Fprintf(w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%d</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+			trnType, t.Completed_at.UTC().Format(ofxTime), ofxAmount(amount), t.ID, ofxEscape(name), ofxEscape(t.Description))
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+
+	balance := stream.Balance()
+
+	fmt.Fprint(w, "</BANKTRANLIST>\n")
+	fmt.Fprintf(w, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", ofxAmount(balance.Balance), balance.Completed_at.UTC().Format(ofxTime))
+	fmt.Fprint(w, "</STMTRS></STMTTRNRS></BANKMSGSRSV1>\n")
+	fmt.Fprint(w, "</OFX>\n")
+
+	return w.Flush()
+}
+
+// ofxAmount renders an amount stored in cents as the decimal OFX expects.
+func ofxAmount(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func ofxEscape(value string) string {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (b *BankController) RefreshToken() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		input := RefreshTokenRequest{}
+
+		if err := ctx.BodyParser(&input); err != nil {
+			return ErrInvalidJson.Wrap(err)
+		}
+
+		if err := validateStruct(input); err != nil {
+			return err
+		}
+
+		pair, err := b.tokens.Refresh(ctx.UserContext(), input.RefreshToken)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(pair)
+	}
+}
+
+func (b *BankController) JWKS() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return ctx.Status(fiber.StatusOK).JSON(b.tokens.Keys())
+	}
+}
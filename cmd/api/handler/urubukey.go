@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/url"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UrubuKeyRequest struct {
+	Kind string `json:"kind" validate:"required,oneof=random email phone document"`
+	Key  string `json:"key" validate:"required_unless=Kind random"`
+}
+
+func (b *BankController) RegisterUrubuKey() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		input := UrubuKeyRequest{}
+
+		if err := ctx.BodyParser(&input); err != nil {
+			return ErrInvalidJson.Wrap(err)
+		}
+
+		if err := validateStruct(input); err != nil {
+			return err
+		}
+
+		id, _ := ctx.ParamsInt("id")
+
+		registered, err := b.bankService.RegisterUrubuKey(ctx.UserContext(), id, input.Kind, input.Key)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(registered)
+	}
+}
+
+func (b *BankController) ListUrubuKeys() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+
+		keys, err := b.bankService.ListUrubuKeys(ctx.UserContext(), id)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(keys)
+	}
+}
+
+func (b *BankController) DeleteUrubuKey() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, _ := ctx.ParamsInt("id")
+
+		key, err := url.PathUnescape(ctx.Params("key"))
+		if err != nil {
+			return InvalidParamErr.Wrap(err)
+		}
+
+		if err := b.bankService.DeleteUrubuKey(ctx.UserContext(), id, domain.UrubuKey(key)); err != nil {
+			return err
+		}
+
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func (b *BankController) LookupUrubuKey() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key, err := url.PathUnescape(ctx.Params("key"))
+		if err != nil {
+			return InvalidParamErr.Wrap(err)
+		}
+
+		owner, err := b.bankService.LookupUrubuKey(ctx.UserContext(), key)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(owner)
+	}
+}
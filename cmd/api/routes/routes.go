@@ -1,11 +1,16 @@
 package routes
 
 import (
-	"database/sql"
+	"log/slog"
 
 	"github.com/FelipeMCassiano/urubu_bank/cmd/api/handler"
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
 	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
-	"github.com/go-redis/redis"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/FelipeMCassiano/urubu_bank/internal/health"
+	"github.com/FelipeMCassiano/urubu_bank/internal/logging"
+	"github.com/FelipeMCassiano/urubu_bank/internal/metrics"
+	"github.com/FelipeMCassiano/urubu_bank/internal/tracing"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -14,14 +19,23 @@ type Router interface {
 }
 
 type router struct {
-	eng   *fiber.App
-	rg    fiber.Router
-	db    *sql.DB
-	redis *redis.Client
+	eng        *fiber.App
+	rg         fiber.Router
+	repository bank.Respository
+	sessions   auth.SessionStore
+	tokens     auth.Tokens
+	checker    *health.Checker
+	cfg        config.Config
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
 }
 
-func NewRouter(eng *fiber.App, db *sql.DB, redis *redis.Client) Router {
-	return &router{eng: eng, db: db, redis: redis}
+// NewRouter serves the API on top of repository and sessions, which can be
+// the Postgres and Redis implementations or bank.NewMemoryRepository and
+// auth.NewMemoryStore when neither is available. tokens is nil unless bearer
+// authentication is enabled.
+func NewRouter(eng *fiber.App, repository bank.Respository, sessions auth.SessionStore, tokens auth.Tokens, checker *health.Checker, cfg config.Config, metrics *metrics.Metrics, logger *slog.Logger) Router {
+	return &router{eng: eng, repository: repository, sessions: sessions, tokens: tokens, checker: checker, cfg: cfg, metrics: metrics, logger: logger}
 }
 
 func (r *router) MapRoutes() {
@@ -30,20 +44,54 @@ func (r *router) MapRoutes() {
 }
 
 func (r *router) setGroup() {
+	r.eng.Use(tracing.Middleware())
+	r.eng.Use(logging.Middleware(r.logger))
+	r.eng.Use(r.metrics.Middleware())
 	r.rg = r.eng.Group("")
 }
 
 func (r *router) buildRoutes() {
-	repo := bank.NewRepository(r.db, r.redis)
-	service := bank.NewService(repo)
-	handler := handler.NewBank(service)
+	health := handler.NewHealth(r.checker)
 
-	r.rg.Post("/costumers/:id/transacoes", handler.IsAuthenticated(), handler.CreateTransaction())
+	r.rg.Get("/healthz", health.Liveness())
+	r.rg.Get("/readyz", health.Readiness())
+	r.rg.Get("/metrics", r.metrics.Handler())
+
+	service := bank.NewTracedService(bank.NewService(r.repository, r.cfg, r.logger))
+	sessionService := auth.NewSessions(r.sessions, r.cfg.Auth.SessionTTL)
+	handler := handler.NewBank(service, sessionService, r.tokens, r.cfg, r.metrics, r.logger)
+
+	r.rg.Post("/costumers/:id/transacoes", handler.IsAuthenticated(), handler.IsAuthorized(), handler.Idempotent(), handler.CreateTransaction())
+	r.rg.Post("/costumers/:id/transacoes/:txid/reverse", handler.IsAuthenticated(), handler.IsAuthorized(), handler.Idempotent(), handler.ReverseTransaction())
 	r.rg.Post("/costumers/create", handler.CreateNewAccount())
-	r.rg.Post("/costumers/:id/depositymoney", handler.IsAuthenticated(), handler.DeposityMoney())
-	r.rg.Get("/costumers/:id/bankstatement", handler.IsAuthenticated(), handler.GetBankStatement())
+	r.rg.Post("/costumers/:id/depositymoney", handler.IsAuthenticated(), handler.IsAuthorized(), handler.Idempotent(), handler.DeposityMoney())
+	r.rg.Get("/costumers/:id/bankstatement", handler.IsAuthenticated(), handler.IsAuthorized(), handler.GetBankStatement())
 	r.rg.Get("/costumers/search", handler.IsAuthenticated(), handler.SearchCostumerByName())
 	r.rg.Post("/costumers/login", handler.Login())
 	r.rg.Get("/costumers/logout", handler.IsAuthenticated(), handler.Logout())
 	r.rg.Post("/costumers/urubutrading", handler.IsAuthenticated(), handler.UrubuTrading())
+	r.rg.Put("/costumers/:id/credentials", handler.IsAuthenticated(), handler.IsAuthorized(), handler.UpdateCredentials())
+
+	if r.tokens != nil {
+		r.rg.Post("/costumers/refresh", handler.RefreshToken())
+		r.rg.Get("/.well-known/jwks.json", handler.JWKS())
+	}
+
+	r.rg.Get("/urubukeys/:key", handler.IsAuthenticated(), handler.RateLimit("urubukeys", r.cfg.RateLimit.UrubuKeyLookups, r.cfg.RateLimit.Window), handler.LookupUrubuKey())
+
+	urubukeys := r.rg.Group("/costumers/:id/urubukeys", handler.IsAuthenticated(), handler.IsAuthorized())
+	urubukeys.Post("", handler.RegisterUrubuKey())
+	urubukeys.Get("", handler.ListUrubuKeys())
+	urubukeys.Delete("/:key", handler.DeleteUrubuKey())
+
+	sessions := r.rg.Group("/costumers/:id/sessions", handler.IsAuthenticated(), handler.IsAuthorized())
+	sessions.Get("", handler.ListSessions())
+	sessions.Delete("/:sessionid", handler.RevokeSession())
+
+	scheduled := r.rg.Group("/costumers/:id/scheduled", handler.IsAuthenticated(), handler.IsAuthorized())
+	scheduled.Post("", handler.CreateScheduledTransfer())
+	scheduled.Get("", handler.ListScheduledTransfers())
+	scheduled.Get("/:scheduledid", handler.GetScheduledTransfer())
+	scheduled.Put("/:scheduledid", handler.UpdateScheduledTransfer())
+	scheduled.Delete("/:scheduledid", handler.CancelScheduledTransfer())
 }
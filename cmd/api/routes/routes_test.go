@@ -0,0 +1,420 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
+	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/FelipeMCassiano/urubu_bank/internal/health"
+	"github.com/FelipeMCassiano/urubu_bank/internal/metrics"
+	"github.com/FelipeMCassiano/urubu_bank/internal/testutil"
+	"github.com/gofiber/fiber/v2"
+)
+
+const lookupLimit = 3
+
+// testServer runs the whole router on the in-memory repository, cache and
+// session store. vars fills {name} placeholders in paths, headers and bodies;
+// cookies holds the session cookie of each logged in costumer.
+type testServer struct {
+	t       *testing.T
+	app     *fiber.App
+	repo    *bank.MemoryRepository
+	tokens  auth.Tokens
+	vars    map[string]string
+	cookies map[string]string
+	served  map[string]bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := testutil.Config()
+	cfg.Auth.Mode = config.AuthModeBoth
+	cfg.RateLimit.UrubuKeyLookups = lookupLimit
+	cfg.RateLimit.Window = time.Minute
+
+	key, err := auth.GenerateKey("test")
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	keyset, err := json.Marshal(auth.JWKS{Keys: []auth.JWK{key}})
+	if err != nil {
+		t.Fatalf("encoding keyset: %v", err)
+	}
+	cfg.Auth.KeysetFile = filepath.Join(t.TempDir(), "keyset.json")
+	if err := os.WriteFile(cfg.Auth.KeysetFile, keyset, 0o600); err != nil {
+		t.Fatalf("writing keyset: %v", err)
+	}
+	keys, err := auth.NewKeyRing(cfg.Auth.KeysetFile)
+	if err != nil {
+		t.Fatalf("loading keyset: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := cache.NewMemory()
+	repo := bank.NewMemoryRepository(store, cfg)
+	checker := health.New(health.Check{Name: "cache", Run: store.Ping})
+
+	ts := &testServer{
+		t:       t,
+		app:     fiber.New(fiber.Config{ErrorHandler: apperr.Handler(logger)}),
+		repo:    repo,
+		tokens:  auth.NewTokens(keys, store, cfg.Auth),
+		vars:    map[string]string{},
+		cookies: map[string]string{},
+		served:  map[string]bool{},
+	}
+
+	// Registered first, so after Next the context points at the route that
+	// answered the request.
+	ts.app.Use(func(ctx *fiber.Ctx) error {
+		err := ctx.Next()
+		ts.served[ctx.Method()+" "+ctx.Route().Path] = true
+		return err
+	})
+
+	NewRouter(ts.app, repo, auth.NewMemoryStore(), ts.tokens, checker, cfg, metrics.New(nil), logger).MapRoutes()
+
+	return ts
+}
+
+type request struct {
+	method string
+	path   string
+	body   string
+	as     string
+	bearer string
+	header map[string]string
+}
+
+type response struct {
+	status  int
+	body    []byte
+	header  http.Header
+	cookies []*http.Cookie
+}
+
+func (ts *testServer) expand(value string) string {
+	for name, v := range ts.vars {
+		value = strings.ReplaceAll(value, "{"+name+"}", v)
+	}
+	return value
+}
+
+func (ts *testServer) do(r request) response {
+	ts.t.Helper()
+
+	req := httptest.NewRequest(r.method, ts.expand(r.path), strings.NewReader(ts.expand(r.body)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	for name, value := range r.header {
+		req.Header.Set(name, ts.expand(value))
+	}
+	if r.as != "" {
+		req.AddCookie(&http.Cookie{Name: "session-name", Value: ts.cookies[r.as]})
+	}
+	if r.bearer != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+ts.expand(r.bearer))
+	}
+
+	resp, err := ts.app.Test(req, -1)
+	if err != nil {
+		ts.t.Fatalf("%s %s: %v", r.method, r.path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ts.t.Fatalf("%s %s: reading body: %v", r.method, r.path, err)
+	}
+
+	return response{status: resp.StatusCode, body: body, header: resp.Header, cookies: resp.Cookies()}
+}
+
+// signup opens an account through the API and logs into it with a cookie,
+// saving its id as {alias}, its random urubukey as {alias}key and its CPF as
+// {alias}cpf.
+func (ts *testServer) signup(alias, fullname string) {
+	ts.t.Helper()
+
+	ts.vars[alias+"cpf"] = testutil.CPF()
+
+	created := ts.do(request{method: "POST", path: "/costumers/create", body: `{"fullname":"` + fullname + `","birth":"01/01/1990","limit":1000,"password":"pw","document":"{` + alias + `cpf}","email":"` + alias + `@urubu.test"}`})
+	if created.status != fiber.StatusOK {
+		ts.t.Fatalf("creating %s: %d %s", alias, created.status, created.body)
+	}
+	ts.vars[alias] = lookup(ts.t, created.body, "id")
+	ts.vars[alias+"key"] = lookup(ts.t, created.body, "urubukey")
+
+	login := ts.do(request{method: "POST", path: "/costumers/login", body: `{"login":"{` + alias + `}","password":"pw"}`})
+	if login.status != fiber.StatusOK {
+		ts.t.Fatalf("logging in %s: %d %s", alias, login.status, login.body)
+	}
+	ts.cookies[alias] = sessionCookie(ts.t, login)
+}
+
+func sessionCookie(t *testing.T, resp response) string {
+	t.Helper()
+
+	for _, cookie := range resp.cookies {
+		if cookie.Name == "session-name" {
+			return cookie.Value
+		}
+	}
+
+	t.Fatalf("no session cookie in response %d %s", resp.status, resp.body)
+	return ""
+}
+
+// lookup walks a dotted path such as "LastTransactions.0.id" through a JSON
+// body and returns what it finds as a string.
+func lookup(t *testing.T, body []byte, path string) string {
+	t.Helper()
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i >= len(node) {
+				t.Fatalf("no %s in %s", path, body)
+			}
+			value = node[i]
+		default:
+			t.Fatalf("no %s in %s", path, body)
+		}
+	}
+
+	switch leaf := value.(type) {
+	case string:
+		return leaf
+	case float64:
+		return strconv.FormatFloat(leaf, 'f', -1, 64)
+	case nil:
+		t.Fatalf("no %s in %s", path, body)
+	}
+
+	t.Fatalf("%s in %s is not a scalar", path, body)
+	return ""
+}
+
+func TestRoutes(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.signup("payor", "Ana Souza")
+	ts.signup("payee", "Ana Souza")
+	ts.signup("admin", "Root")
+	if err := ts.repo.SetAdmin(mustAtoi(t, ts.vars["admin"]), true); err != nil {
+		t.Fatalf("granting admin: %v", err)
+	}
+
+	bearer := ts.do(request{method: "POST", path: "/costumers/login", body: `{"login":"{payor}","password":"pw","mode":"bearer"}`})
+	if bearer.status != fiber.StatusOK {
+		t.Fatalf("bearer login: %d %s", bearer.status, bearer.body)
+	}
+	ts.vars["access"] = lookup(t, bearer.body, "access_token")
+	ts.vars["refresh"] = lookup(t, bearer.body, "refresh_token")
+
+	ghost, err := ts.tokens.Issue(context.Background(), 999999)
+	if err != nil {
+		t.Fatalf("issuing token for a missing costumer: %v", err)
+	}
+	ts.vars["ghost"] = ghost.AccessToken
+
+	ts.vars["newcpf"] = testutil.CPF()
+	ts.vars["othercpf"] = testutil.CPF()
+	ts.vars["runat"] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	ts.vars["pastrunat"] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	ts.vars["payorcpfmasked"] = ts.vars["payorcpf"][:3] + "." + ts.vars["payorcpf"][3:6] + "." + ts.vars["payorcpf"][6:9] + "-" + ts.vars["payorcpf"][9:]
+
+	// Only what the table itself serves counts towards route coverage.
+	ts.served = map[string]bool{}
+
+	// Cases run in order and build on each other: {name} values saved by
+	// one case are used by the cases after it.
+	tests := []struct {
+		name     string
+		request  request
+		status   int
+		contains []string
+		save     map[string]string
+	}{
+		{name: "liveness", request: request{method: "GET", path: "/healthz"}, status: 200},
+		{name: "readiness", request: request{method: "GET", path: "/readyz"}, status: 200},
+		{name: "metrics", request: request{method: "GET", path: "/metrics"}, status: 200, contains: []string{"urubu"}},
+
+		{name: "create account with a shared name", request: request{method: "POST", path: "/costumers/create", body: `{"fullname":"Ana Souza","birth":"01/01/1990","limit":0,"password":"pw","document":"{newcpf}"}`}, status: 200, contains: []string{`"fullname":"Ana Souza"`}},
+		{name: "create account with a taken document", request: request{method: "POST", path: "/costumers/create", body: `{"fullname":"Bia","birth":"01/01/1990","limit":0,"password":"pw","document":"{payorcpfmasked}"}`}, status: 409},
+		{name: "create account with a taken email", request: request{method: "POST", path: "/costumers/create", body: `{"fullname":"Bia","birth":"01/01/1990","limit":0,"password":"pw","document":"{othercpf}","email":"PAYOR@urubu.test"}`}, status: 409, contains: []string{"email_taken"}},
+		{name: "create account with an invalid CPF", request: request{method: "POST", path: "/costumers/create", body: `{"fullname":"Bia","birth":"01/01/1990","limit":0,"password":"pw","document":"123.456.789-00"}`}, status: 422, contains: []string{"invalid_document"}},
+		{name: "create account without a document", request: request{method: "POST", path: "/costumers/create", body: `{"fullname":"Bia","birth":"01/01/1990","limit":0,"password":"pw"}`}, status: 422, contains: []string{"validation_failed"}},
+		{name: "create account with bad json", request: request{method: "POST", path: "/costumers/create", body: `{`}, status: 400},
+
+		{name: "login by account number", request: request{method: "POST", path: "/costumers/login", body: `{"login":"{payor}","password":"pw"}`}, status: 200},
+		{name: "login by formatted CPF", request: request{method: "POST", path: "/costumers/login", body: `{"login":"{payorcpfmasked}","password":"pw"}`}, status: 200},
+		{name: "login by email", request: request{method: "POST", path: "/costumers/login", body: `{"login":"Payor@Urubu.test","password":"pw"}`}, status: 200},
+		{name: "login with the wrong password", request: request{method: "POST", path: "/costumers/login", body: `{"login":"{payor}","password":"nope"}`}, status: 401, contains: []string{"invalid_credentials"}},
+		{name: "login to an unknown account", request: request{method: "POST", path: "/costumers/login", body: `{"login":"999999","password":"pw"}`}, status: 401, contains: []string{"invalid_credentials"}},
+		{name: "login by an unregistered CPF", request: request{method: "POST", path: "/costumers/login", body: `{"login":"{othercpf}","password":"pw"}`}, status: 401, contains: []string{"invalid_credentials"}},
+		{name: "login by an unregistered email", request: request{method: "POST", path: "/costumers/login", body: `{"login":"nobody@urubu.test","password":"pw"}`}, status: 401, contains: []string{"invalid_credentials"}},
+		{name: "login by full name", request: request{method: "POST", path: "/costumers/login", body: `{"login":"Ana Souza","password":"pw"}`}, status: 422, contains: []string{"invalid_login"}},
+		{name: "login for a bearer token", request: request{method: "POST", path: "/costumers/login", body: `{"login":"{payor}","password":"pw","mode":"bearer"}`}, status: 200, save: map[string]string{"access2": "access_token"}},
+
+		{name: "deposit", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", as: "payor", body: `{"value":500,"kind":"credit","description":"salary"}`}, status: 200, contains: []string{`"newbalance":500`}},
+		{name: "deposit without a session", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", body: `{"value":500,"kind":"credit","description":"salary"}`}, status: 401},
+		{name: "deposit into someone else's account", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", as: "payee", body: `{"value":500,"kind":"credit","description":"salary"}`}, status: 403},
+		{name: "deposit as admin", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", as: "admin", body: `{"value":100,"kind":"credit","description":"bonus"}`}, status: 200, contains: []string{`"newbalance":600`}},
+		{name: "deposit with an idempotency key", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", as: "payor", header: map[string]string{"Idempotency-Key": "dep-1"}, body: `{"value":100,"kind":"credit","description":"once"}`}, status: 200, contains: []string{`"newbalance":700`}},
+		{name: "deposit replayed by its idempotency key", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", as: "payor", header: map[string]string{"Idempotency-Key": "dep-1"}, body: `{"value":100,"kind":"credit","description":"once"}`}, status: 200, contains: []string{`"newbalance":700`}},
+		{name: "idempotency key reused for another request", request: request{method: "POST", path: "/costumers/{payor}/depositymoney", as: "payor", header: map[string]string{"Idempotency-Key": "dep-1"}, body: `{"value":200,"kind":"credit","description":"once"}`}, status: 409},
+
+		{name: "transfer", request: request{method: "POST", path: "/costumers/{payor}/transacoes", as: "payor", body: `{"value":300,"kind":"debit","description":"rent","payeeurubukey":"{payeekey}"}`}, status: 201, contains: []string{`"balance":400`}},
+		{name: "transfer beyond the limit", request: request{method: "POST", path: "/costumers/{payor}/transacoes", as: "payor", body: `{"value":100000,"kind":"debit","description":"yacht","payeeurubukey":"{payeekey}"}`}, status: 422},
+		{name: "transfer to an unknown urubukey", request: request{method: "POST", path: "/costumers/{payor}/transacoes", as: "payor", body: `{"value":1,"kind":"debit","description":"lost","payeeurubukey":"nobody"}`}, status: 404},
+		{name: "transfer with a bearer token", request: request{method: "POST", path: "/costumers/{payor}/transacoes", bearer: "{access}", body: `{"value":50,"kind":"debit","description":"coffee","payeeurubukey":"{payeekey}"}`}, status: 201, contains: []string{`"balance":350`}},
+		{name: "transfer with a forged bearer token", request: request{method: "POST", path: "/costumers/{payor}/transacoes", bearer: "forged", body: `{"value":50,"kind":"debit","description":"coffee","payeeurubukey":"{payeekey}"}`}, status: 401},
+
+		{name: "statement", request: request{method: "GET", path: "/costumers/{payor}/bankstatement", as: "payor"}, status: 200, contains: []string{`"balance":350`}, save: map[string]string{"txid": "LastTransactions.0.id"}},
+		{name: "statement as csv", request: request{method: "GET", path: "/costumers/{payor}/bankstatement?format=csv", as: "payor"}, status: 200, contains: []string{"id,completed_at,kind,value,description,payor,payee", "coffee"}},
+		{name: "statement as ofx", request: request{method: "GET", path: "/costumers/{payor}/bankstatement?format=ofx", as: "payor"}, status: 200, contains: []string{"<OFX>", "<TRNAMT>-0.50</TRNAMT>"}},
+		{name: "statement in an unknown format", request: request{method: "GET", path: "/costumers/{payor}/bankstatement?format=xml", as: "payor"}, status: 406},
+		{name: "statement with an invalid date", request: request{method: "GET", path: "/costumers/{payor}/bankstatement?to=2026-13-01", as: "payor"}, status: 400},
+		{name: "statement of someone else", request: request{method: "GET", path: "/costumers/{payor}/bankstatement", as: "payee"}, status: 403},
+		{name: "statement for a token of a missing costumer", request: request{method: "GET", path: "/costumers/{payor}/bankstatement", bearer: "{ghost}"}, status: 403},
+
+		{name: "reverse a transfer", request: request{method: "POST", path: "/costumers/{payor}/transacoes/{txid}/reverse", as: "payor"}, status: 201, contains: []string{`"reversed_transaction_id":{txid}`}},
+		{name: "reverse a transfer twice", request: request{method: "POST", path: "/costumers/{payor}/transacoes/{txid}/reverse", as: "payor"}, status: 409},
+		{name: "reverse an unknown transfer", request: request{method: "POST", path: "/costumers/{payor}/transacoes/999999/reverse", as: "payor"}, status: 404},
+		{name: "reverse with a malformed id", request: request{method: "POST", path: "/costumers/{payor}/transacoes/abc/reverse", as: "payor"}, status: 400},
+
+		{name: "search by name", request: request{method: "GET", path: "/costumers/search?name=ana", as: "payor"}, status: 200, contains: []string{`"fullname":"Ana Souza"`}},
+		{name: "search without a session", request: request{method: "GET", path: "/costumers/search?name=ana"}, status: 401},
+
+		{name: "urubu trading", request: request{method: "POST", path: "/costumers/urubutrading", as: "payor", body: `{"login":"{payor}","password":"pw","value":10}`}, status: 200},
+		{name: "urubu trading with the wrong password", request: request{method: "POST", path: "/costumers/urubutrading", as: "payor", body: `{"login":"{payor}","password":"nope","value":10}`}, status: 401, contains: []string{"invalid_credentials"}},
+		{name: "urubu trading with an unknown login", request: request{method: "POST", path: "/costumers/urubutrading", as: "payor", body: `{"login":"{othercpf}","password":"pw","value":10}`}, status: 401, contains: []string{"invalid_credentials"}},
+
+		{name: "set the email", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payee", body: `{"password":"pw","email":"Ana.New@urubu.test"}`}, status: 200, contains: []string{`"email":"ana.new@urubu.test"`, `"document":"{payeecpf}"`}},
+		{name: "login by the replaced email", request: request{method: "POST", path: "/costumers/login", body: `{"login":"payee@urubu.test","password":"pw"}`}, status: 401},
+		{name: "login by the new email", request: request{method: "POST", path: "/costumers/login", body: `{"login":"ana.new@urubu.test","password":"pw"}`}, status: 200},
+		{name: "set the document already on the account", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payee", body: `{"password":"pw","document":"{payeecpf}"}`}, status: 200},
+		{name: "replace the document", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payee", body: `{"password":"pw","document":"{othercpf}"}`}, status: 409, contains: []string{"document_already_set"}},
+		{name: "set a taken email", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payee", body: `{"password":"pw","email":"payor@urubu.test"}`}, status: 409, contains: []string{"email_taken"}},
+		{name: "set credentials with the wrong password", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payee", body: `{"password":"nope","email":"x@urubu.test"}`}, status: 401, contains: []string{"invalid_credentials"}},
+		{name: "set credentials without any", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payee", body: `{"password":"pw"}`}, status: 422},
+		{name: "set someone else's credentials", request: request{method: "PUT", path: "/costumers/{payee}/credentials", as: "payor", body: `{"password":"pw","email":"x@urubu.test"}`}, status: 403},
+
+		{name: "refresh", request: request{method: "POST", path: "/costumers/refresh", body: `{"refresh_token":"{refresh}"}`}, status: 200, save: map[string]string{"refresh2": "refresh_token"}},
+		{name: "refresh token reused", request: request{method: "POST", path: "/costumers/refresh", body: `{"refresh_token":"{refresh}"}`}, status: 401},
+		{name: "refresh after reuse revoked the family", request: request{method: "POST", path: "/costumers/refresh", body: `{"refresh_token":"{refresh2}"}`}, status: 401},
+		{name: "access token of a revoked family", request: request{method: "GET", path: "/costumers/{payor}/bankstatement", bearer: "{access}"}, status: 401},
+		{name: "jwks", request: request{method: "GET", path: "/.well-known/jwks.json"}, status: 200, contains: []string{`"alg":"EdDSA"`}},
+
+		{name: "urubukey lookup", request: request{method: "GET", path: "/urubukeys/{payeekey}", as: "payor"}, status: 200, contains: []string{`"bank":"urubu_bank"`, `"name":"Ana S."`}},
+		{name: "urubukey lookup of an unknown key", request: request{method: "GET", path: "/urubukeys/nobody", as: "payor"}, status: 404},
+		{name: "urubukey lookup at the limit", request: request{method: "GET", path: "/urubukeys/{payeekey}", as: "payor"}, status: 200},
+		{name: "urubukey lookup past the limit", request: request{method: "GET", path: "/urubukeys/{payeekey}", as: "payor"}, status: 429},
+		{name: "urubukey lookup past the limit with another token", request: request{method: "GET", path: "/urubukeys/{payeekey}", bearer: "{access2}"}, status: 429},
+		{name: "urubukey lookup without a session", request: request{method: "GET", path: "/urubukeys/{payeekey}"}, status: 401},
+
+		{name: "register an email urubukey", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payor", body: `{"kind":"email","key":"payor@urubu.test"}`}, status: 201},
+		{name: "register a taken urubukey", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payor", body: `{"kind":"email","key":"payor@urubu.test"}`}, status: 409},
+		{name: "register a phone urubukey", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payor", body: `{"kind":"phone","key":"+55 (11) 99999-0000"}`}, status: 201, contains: []string{`"key":"+5511999990000"`}},
+		{name: "register the own CPF as urubukey", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payor", body: `{"kind":"document","key":"{payorcpfmasked}"}`}, status: 201, contains: []string{`"key":"{payorcpf}"`}},
+		{name: "register someone else's CPF as urubukey", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payor", body: `{"kind":"document","key":"{payeecpf}"}`}, status: 422, contains: []string{"urubukey_not_own_document"}},
+		{name: "register an urubukey of an unknown kind", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payor", body: `{"kind":"pix","key":"x"}`}, status: 422},
+		{name: "register an urubukey for someone else", request: request{method: "POST", path: "/costumers/{payor}/urubukeys", as: "payee", body: `{"kind":"random"}`}, status: 403},
+		{name: "list urubukeys", request: request{method: "GET", path: "/costumers/{payor}/urubukeys", as: "payor"}, status: 200, contains: []string{"{payorkey}", "payor@urubu.test", "+5511999990000"}},
+		{name: "delete an urubukey", request: request{method: "DELETE", path: "/costumers/{payor}/urubukeys/%2B5511999990000", as: "payor"}, status: 204},
+		{name: "delete an unknown urubukey", request: request{method: "DELETE", path: "/costumers/{payor}/urubukeys/%2B5511999990000", as: "payor"}, status: 404},
+
+		{name: "list sessions", request: request{method: "GET", path: "/costumers/{payor}/sessions", as: "payor"}, status: 200, contains: []string{`"current":true`}},
+		{name: "list someone else's sessions", request: request{method: "GET", path: "/costumers/{payor}/sessions", as: "payee"}, status: 403},
+		{name: "list sessions as admin", request: request{method: "GET", path: "/costumers/{payee}/sessions", as: "admin"}, status: 200, contains: []string{`"current":false`}, save: map[string]string{"payeesession": "0.id"}},
+		{name: "revoke a session as admin", request: request{method: "DELETE", path: "/costumers/{payee}/sessions/{payeesession}", as: "admin"}, status: 204},
+		{name: "revoke a revoked session", request: request{method: "DELETE", path: "/costumers/{payee}/sessions/{payeesession}", as: "admin"}, status: 404},
+		{name: "revoked session", request: request{method: "GET", path: "/costumers/{payee}/sessions", as: "payee"}, status: 401},
+
+		{name: "schedule a transfer", request: request{method: "POST", path: "/costumers/{payor}/scheduled", as: "payor", body: `{"value":100,"description":"gym","payeeurubukey":"{payeekey}","run_at":"{runat}","recurrence":"monthly"}`}, status: 201, save: map[string]string{"scheduled": "id"}},
+		{name: "schedule a transfer in the past", request: request{method: "POST", path: "/costumers/{payor}/scheduled", as: "payor", body: `{"value":100,"description":"gym","payeeurubukey":"{payeekey}","run_at":"{pastrunat}","recurrence":"once"}`}, status: 422},
+		{name: "list scheduled transfers", request: request{method: "GET", path: "/costumers/{payor}/scheduled", as: "payor"}, status: 200, contains: []string{`"description":"gym"`}},
+		{name: "get a scheduled transfer", request: request{method: "GET", path: "/costumers/{payor}/scheduled/{scheduled}", as: "payor"}, status: 200, contains: []string{`"recurrence":"monthly"`}},
+		{name: "get a scheduled transfer with a malformed id", request: request{method: "GET", path: "/costumers/{payor}/scheduled/abc", as: "payor"}, status: 400},
+		{name: "update a scheduled transfer", request: request{method: "PUT", path: "/costumers/{payor}/scheduled/{scheduled}", as: "payor", body: `{"value":150,"description":"gym","payeeurubukey":"{payeekey}","run_at":"{runat}","recurrence":"weekly"}`}, status: 200, contains: []string{`"value":150`, `"recurrence":"weekly"`}},
+		{name: "cancel a scheduled transfer", request: request{method: "DELETE", path: "/costumers/{payor}/scheduled/{scheduled}", as: "payor"}, status: 204},
+		{name: "cancel a cancelled scheduled transfer", request: request{method: "DELETE", path: "/costumers/{payor}/scheduled/{scheduled}", as: "payor"}, status: 404},
+
+		{name: "logout", request: request{method: "GET", path: "/costumers/logout", as: "payor"}, status: 200},
+		{name: "session after logout", request: request{method: "GET", path: "/costumers/{payor}/sessions", as: "payor"}, status: 401},
+		{name: "logout of a bearer token", request: request{method: "GET", path: "/costumers/logout", bearer: "{access2}"}, status: 200},
+		{name: "bearer token after logout", request: request{method: "GET", path: "/costumers/{payor}/urubukeys", bearer: "{access2}"}, status: 401},
+	}
+
+	for _, tt := range tests {
+		ok := t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do(tt.request)
+
+			if resp.status != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.status, tt.status, resp.body)
+			}
+			for _, want := range tt.contains {
+				if want = ts.expand(want); !strings.Contains(string(resp.body), want) {
+					t.Errorf("body does not contain %s: %s", want, resp.body)
+				}
+			}
+			for name, path := range tt.save {
+				ts.vars[name] = lookup(t, resp.body, path)
+			}
+		})
+		if !ok && len(tt.save) > 0 {
+			t.Fatalf("later cases depend on %q", tt.name)
+		}
+	}
+
+	var missed []string
+	for _, route := range ts.app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+		if key := route.Method + " " + route.Path; !ts.served[key] {
+			missed = append(missed, key)
+		}
+	}
+	sort.Strings(missed)
+
+	if len(missed) > 0 {
+		t.Errorf("routes without a case: %v", missed)
+	}
+}
+
+func mustAtoi(t *testing.T, value string) int {
+	t.Helper()
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", value, err)
+	}
+
+	return parsed
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
+)
+
+const keygenUsage = "usage: keygen [kid]"
+
+// runKeygen prints a new Ed25519 key. To rotate, add it first in the keyset
+// file's keys, keep the previous keys until their tokens expire and send the
+// API a SIGHUP.
+func runKeygen(w io.Writer, args []string) error {
+	if len(args) > 1 {
+		return errors.New(keygenUsage)
+	}
+
+	kid := time.Now().UTC().Format("20060102T150405Z")
+	if len(args) == 1 {
+		kid = args[0]
+	}
+
+	key, err := auth.GenerateKey(kid)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(key)
+}
@@ -3,45 +3,218 @@ package main
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/FelipeMCassiano/urubu_bank/cmd/api/routes"
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
+	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/FelipeMCassiano/urubu_bank/internal/health"
+	"github.com/FelipeMCassiano/urubu_bank/internal/logging"
+	"github.com/FelipeMCassiano/urubu_bank/internal/metrics"
+	"github.com/FelipeMCassiano/urubu_bank/internal/migrations"
+	"github.com/FelipeMCassiano/urubu_bank/internal/tracing"
 	"github.com/go-redis/redis"
 	"github.com/gofiber/fiber/v2"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		if err := runKeygen(os.Stdout, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = db.PingContext(context.Background())
+	logger := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	logger.Info("effective configuration", "config", cfg.Redacted())
+
+	db, err := sql.Open("postgres", cfg.Database.URL)
 	if err != nil {
-		log.Fatal(err)
+		fatal(logger, "opening database", err)
+	}
+
+	err = waitFor(context.Background(), logger, "postgres", cfg.App.StartupTimeout, db.PingContext)
+	if err != nil {
+		fatal(logger, "connecting to postgres", err)
 	}
 
-	defer db.Close()
+	logger.Info("connected to postgres")
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fatal(logger, "loading migrations", err)
+	}
 
-	log.Println("Connected!")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(context.Background(), logger, migrator, os.Args[2:])
+		db.Close()
+		if err != nil {
+			fatal(logger, "running migrations", err)
+		}
+		return
+	}
+
+	if cfg.Database.MigrateOnStart {
+		if err := runMigrate(context.Background(), logger, migrator, nil); err != nil {
+			fatal(logger, "running migrations", err)
+		}
+	}
 
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "cache:6379",
-		Password: "",
-		DB:       0,
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
 	})
-	if err := redisClient.Ping().Err(); err != nil {
-		log.Fatal(err)
+	err = waitFor(context.Background(), logger, "redis", cfg.App.StartupTimeout, func(ctx context.Context) error {
+		return redisClient.WithContext(ctx).Ping().Err()
+	})
+	if err != nil {
+		fatal(logger, "connecting to redis", err)
 	}
 
-	eng := fiber.New()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal(logger, "setting up tracing", err)
+	}
+
+	metrics := metrics.New(db)
+	metrics.InstrumentRedis(redisClient)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store := cache.NewRedis(redisClient)
+	repository := bank.NewTracedRepository(bank.NewRepository(db, store, cfg, logger))
+	service := bank.NewTracedService(bank.NewService(repository, cfg, logger))
+	scheduler := bank.NewScheduler(service, cfg.Scheduler.Interval, logger)
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.Run(ctx)
+	}()
+
+	eng := fiber.New(fiber.Config{ErrorHandler: apperr.Handler(logger)})
+
+	sessions := auth.NewTracedStore(auth.NewRedisStore(redisClient))
+
+	var tokens auth.Tokens
+	if cfg.Auth.BearerEnabled() {
+		keys, err := auth.NewKeyRing(cfg.Auth.KeysetFile)
+		if err != nil {
+			fatal(logger, "loading auth keyset", err)
+		}
+		go reloadKeys(ctx, logger, keys)
+
+		tokens = auth.NewTokens(keys, store, cfg.Auth)
+	}
 
-	router := routes.NewRouter(eng, db, redisClient)
+	router := routes.NewRouter(eng, repository, sessions, tokens, health.NewChecker(db, store, migrator), cfg, metrics, logger)
 	router.MapRoutes()
 
-	if err := eng.Listen(":" + os.Getenv("APP_PORT")); err != nil {
-		panic(err)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- eng.Listen(":" + cfg.App.Port)
+	}()
+
+	select {
+	case err := <-listenErr:
+		logger.Error("http server stopped", "error", err)
+		stop()
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
+	defer cancel()
+
+	if err := eng.ShutdownWithContext(shutdownCtx); err != nil {
+		logger.Error("http shutdown", "error", err)
+	}
+
+	select {
+	case <-schedulerDone:
+	case <-shutdownCtx.Done():
+		logger.Error("waiting for scheduler", "error", shutdownCtx.Err())
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("flushing traces", "error", err)
+	}
+
+	if err := redisClient.Close(); err != nil {
+		logger.Error("closing redis", "error", err)
+	}
+
+	if err := db.Close(); err != nil {
+		logger.Error("closing database", "error", err)
+	}
+}
+
+// reloadKeys rereads the keyset file on SIGHUP so signing keys rotate
+// without a restart. A bad file is logged and the current keys stay.
+func reloadKeys(ctx context.Context, logger *slog.Logger, keys *auth.KeyRing) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := keys.Reload(); err != nil {
+				logger.Error("reloading auth keyset", "error", err)
+				continue
+			}
+			logger.Info("auth keyset reloaded")
+		}
 	}
 }
+
+// waitFor retries check with exponential backoff until it succeeds or timeout
+// elapses, so the API survives starting before its dependencies.
+func waitFor(ctx context.Context, logger *slog.Logger, name string, timeout time.Duration, check func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := 250 * time.Millisecond
+
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		logger.Warn("dependency not ready", "dependency", name, "retry_in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready after %s: %w", name, timeout, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
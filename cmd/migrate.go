@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/migrations"
+)
+
+const migrateUsage = "usage: migrate [up | down [steps] | version | baseline [version]]"
+
+func runMigrate(ctx context.Context, logger *slog.Logger, migrator *migrations.Migrator, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if errors.Is(err, migrations.ErrNoChange) {
+			logger.InfoContext(ctx, "schema already up to date")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "migrations applied", "count", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid steps %q\n%s", args[1], migrateUsage)
+			}
+			steps = parsed
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if errors.Is(err, migrations.ErrNoChange) {
+			logger.InfoContext(ctx, "no migrations to revert")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "migrations reverted", "count", reverted)
+
+	case "baseline":
+		version := int64(1)
+		if len(args) > 1 {
+			parsed, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid version %q\n%s", args[1], migrateUsage)
+			}
+			version = parsed
+		}
+
+		if err := migrator.Baseline(ctx, version); err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "migrations baselined", "version", version)
+
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "migration version", "version", version, "latest", migrator.Latest())
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	CodeInternal   = "internal_error"
+	CodeValidation = "validation_failed"
+)
+
+// Error is an error the API can describe to its clients: a stable
+// machine-readable code, the HTTP status it maps to, a human message and
+// optional per-field details.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+	Details []FieldError
+	cause   error
+}
+
+type FieldError struct {
+	Field string `json:"field"`
+	Tag   string `json:"tag"`
+	Param string `json:"param,omitempty"`
+}
+
+func New(code string, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors with the same code, so a sentinel keeps matching after
+// Wrap or WithDetails copied it.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e that carries cause for logging without exposing it
+// to clients.
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	detailed := *e
+	detailed.Details = append(append([]FieldError(nil), e.Details...), details...)
+	return &detailed
+}
+
+var (
+	Internal   = New(CodeInternal, http.StatusInternalServerError, "internal server error")
+	Validation = New(CodeValidation, http.StatusUnprocessableEntity, "request validation failed")
+	Timeout    = New("timeout", http.StatusGatewayTimeout, "operation timed out")
+)
+
+// From converts any error into an *Error: typed errors are kept, Fiber errors
+// validator failures and deadlines are translated, and anything else becomes
+// an internal error wrapping the original.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			details = append(details, FieldError{
+				Field: fieldErr.Field(),
+				Tag:   fieldErr.Tag(),
+				Param: fieldErr.Param(),
+			})
+		}
+		return Validation.Wrap(err).WithDetails(details...)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout.Wrap(err)
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return New(statusCode(fiberErr.Code), fiberErr.Code, fiberErr.Message)
+	}
+
+	return Internal.Wrap(err)
+}
+
+func Status(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return From(err).Status
+}
+
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return fmt.Sprintf("http_%d", status)
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -0,0 +1,52 @@
+package apperr
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/logging"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:urubu-bank:problem:"
+)
+
+// Problem is an RFC 7807 problem details document extended with the error
+// code, validation failures and the request ID.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Handler is the Fiber ErrorHandler: it renders every error returned by a
+// handler as problem+json and logs server-side failures with their cause.
+func Handler(logger *slog.Logger) fiber.ErrorHandler {
+	return func(ctx *fiber.Ctx, err error) error {
+		appErr := From(err)
+
+		if appErr.Status >= http.StatusInternalServerError {
+			logger.ErrorContext(ctx.UserContext(), "request failed", "route", ctx.Route().Path, "error", err)
+		}
+
+		problem := Problem{
+			Type:      problemTypePrefix + appErr.Code,
+			Title:     http.StatusText(appErr.Status),
+			Status:    appErr.Status,
+			Detail:    appErr.Message,
+			Instance:  ctx.OriginalURL(),
+			Code:      appErr.Code,
+			Errors:    appErr.Details,
+			RequestID: logging.RequestID(ctx.UserContext()),
+		}
+
+		return ctx.Status(appErr.Status).JSON(problem, ProblemContentType)
+	}
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK is the subset of RFC 7517 used by the keyset file: Ed25519 keys
+// (kty OKP, signed as EdDSA) and shared secrets (kty oct, signed as HS256).
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	X   string `json:"x,omitempty"`
+	D   string `json:"d,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type verifyingKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// Keyset signs with the first key holding private material and verifies
+// with every key, so a rotation adds the new key on top and keeps the old
+// ones until the tokens they signed have expired.
+type Keyset struct {
+	signing   *signingKey
+	verifying map[string]verifyingKey
+	public    JWKS
+}
+
+func ParseKeyset(data []byte) (*Keyset, error) {
+	var file JWKS
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("keyset: %w", err)
+	}
+
+	keyset := &Keyset{
+		verifying: map[string]verifyingKey{},
+		public:    JWKS{Keys: []JWK{}},
+	}
+
+	for _, jwk := range file.Keys {
+		if jwk.Kid == "" {
+			return nil, errors.New("keyset: every key needs a kid")
+		}
+		if _, ok := keyset.verifying[jwk.Kid]; ok {
+			return nil, fmt.Errorf("keyset: duplicate kid %q", jwk.Kid)
+		}
+
+		verifying, signing, err := parseJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("keyset: key %q: %w", jwk.Kid, err)
+		}
+
+		keyset.verifying[jwk.Kid] = verifying
+		if signing != nil && keyset.signing == nil {
+			keyset.signing = signing
+		}
+		if jwk.Kty == "OKP" {
+			keyset.public.Keys = append(keyset.public.Keys, JWK{Kid: jwk.Kid, Kty: jwk.Kty, Crv: jwk.Crv, Alg: "EdDSA", Use: "sig", X: jwk.X})
+		}
+	}
+
+	if keyset.signing == nil {
+		return nil, errors.New("keyset: no key can sign")
+	}
+
+	return keyset, nil
+}
+
+func parseJWK(jwk JWK) (verifyingKey, *signingKey, error) {
+	switch jwk.Kty {
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return verifyingKey{}, nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return verifyingKey{}, nil, errors.New("x must be a base64url Ed25519 public key")
+		}
+		public := ed25519.PublicKey(x)
+		verifying := verifyingKey{method: jwt.SigningMethodEdDSA, key: public}
+
+		if jwk.D == "" {
+			return verifying, nil, nil
+		}
+
+		d, err := base64.RawURLEncoding.DecodeString(jwk.D)
+		if err != nil || len(d) != ed25519.SeedSize {
+			return verifyingKey{}, nil, errors.New("d must be a base64url Ed25519 seed")
+		}
+		private := ed25519.NewKeyFromSeed(d)
+		if !public.Equal(private.Public()) {
+			return verifyingKey{}, nil, errors.New("d does not match x")
+		}
+
+		return verifying, &signingKey{kid: jwk.Kid, method: jwt.SigningMethodEdDSA, key: private}, nil
+
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(k) < 32 {
+			return verifyingKey{}, nil, errors.New("k must be a base64url secret of at least 32 bytes")
+		}
+
+		return verifyingKey{method: jwt.SigningMethodHS256, key: k}, &signingKey{kid: jwk.Kid, method: jwt.SigningMethodHS256, key: k}, nil
+	}
+
+	return verifyingKey{}, nil, fmt.Errorf("unsupported kty %q", jwk.Kty)
+}
+
+// Public lists the Ed25519 public keys for /.well-known/jwks.json. Shared
+// secrets are never published.
+func (k *Keyset) Public() JWKS {
+	return k.public
+}
+
+// KeyRing holds the keyset loaded from a file and swaps it atomically on
+// Reload, so keys rotate without a restart.
+type KeyRing struct {
+	path    string
+	current atomic.Pointer[Keyset]
+}
+
+func NewKeyRing(path string) (*KeyRing, error) {
+	ring := &KeyRing{path: path}
+	if err := ring.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+func (r *KeyRing) Reload() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	keyset, err := ParseKeyset(data)
+	if err != nil {
+		return err
+	}
+
+	r.current.Store(keyset)
+
+	return nil
+}
+
+func (r *KeyRing) Current() *Keyset {
+	return r.current.Load()
+}
+
+// GenerateKey returns a fresh Ed25519 signing key ready for the keyset file.
+func GenerateKey(kid string) (JWK, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return JWK{}, err
+	}
+
+	return JWK{
+		Kid: kid,
+		Kty: "OKP",
+		Crv: "Ed25519",
+		Alg: "EdDSA",
+		Use: "sig",
+		X:   base64.RawURLEncoding.EncodeToString(public),
+		D:   base64.RawURLEncoding.EncodeToString(private.Seed()),
+	}, nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memorySession struct {
+	Session
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	now      func() time.Time
+}
+
+// NewMemoryStore keeps sessions in process memory, for running the API
+// without Redis.
+func NewMemoryStore() SessionStore {
+	return &memoryStore{
+		sessions: map[string]memorySession{},
+		now:      time.Now,
+	}
+}
+
+func (s *memoryStore) lookup(token string) (memorySession, bool) {
+	session, ok := s.sessions[token]
+	if ok && !s.now().Before(session.expiresAt) {
+		delete(s.sessions, token)
+		return memorySession{}, false
+	}
+
+	return session, ok
+}
+
+func (s *memoryStore) Save(ctx context.Context, token string, session Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[token] = memorySession{Session: session, expiresAt: s.now().Add(ttl)}
+
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, token string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.lookup(token)
+	if !ok {
+		return Session{}, SessionNotFoundErr
+	}
+
+	return session.Session, nil
+}
+
+func (s *memoryStore) Touch(ctx context.Context, token string, lastSeen time.Time, ttl time.Duration) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.lookup(token)
+	if !ok {
+		return Session{}, SessionNotFoundErr
+	}
+
+	session.LastSeen = lastSeen
+	session.expiresAt = s.now().Add(ttl)
+	s.sessions[token] = session
+
+	return session.Session, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context, costumerID int) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := []Session{}
+	for token := range s.sessions {
+		session, ok := s.lookup(token)
+		if ok && session.CostumerID == costumerID {
+			sessions = append(sessions, session.Session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created_at.Before(sessions[j].Created_at)
+	})
+
+	return sessions, nil
+}
+
+func (s *memoryStore) Revoke(ctx context.Context, costumerID int, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token := range s.sessions {
+		session, ok := s.lookup(token)
+		if ok && session.CostumerID == costumerID && session.ID == id {
+			delete(s.sessions, token)
+			return nil
+		}
+	}
+
+	return SessionNotFoundErr
+}
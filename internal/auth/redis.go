@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	sessionPrefix = "session:"
+	indexPrefix   = "sessions:"
+)
+
+// redisStore keeps each session as JSON under session:<token> and a hash
+// sessions:<costumer id> mapping session ids to tokens. Index entries whose
+// session expired are pruned when the costumer's sessions are listed.
+type redisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) SessionStore {
+	return &redisStore{client: client}
+}
+
+func indexKey(costumerID int) string {
+	return indexPrefix + strconv.Itoa(costumerID)
+}
+
+func (s *redisStore) Save(ctx context.Context, token string, session Session, ttl time.Duration) error {
+	stored, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.WithContext(ctx).TxPipeline()
+	pipe.Set(sessionPrefix+token, stored, ttl)
+	pipe.HSet(indexKey(session.CostumerID), session.ID, token)
+	pipe.Expire(indexKey(session.CostumerID), ttl)
+
+	_, err = pipe.Exec()
+	return err
+}
+
+func (s *redisStore) Get(ctx context.Context, token string) (Session, error) {
+	return s.get(s.client.WithContext(ctx), token)
+}
+
+func (s *redisStore) get(client *redis.Client, token string) (Session, error) {
+	var session Session
+
+	stored, err := client.Get(sessionPrefix + token).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return Session{}, SessionNotFoundErr
+		}
+		return Session{}, err
+	}
+
+	// Sessions written before metadata was stored hold a bare costumer id;
+	// treat them as expired so their owners log in again.
+	if err := json.Unmarshal(stored, &session); err != nil || session.ID == "" {
+		return Session{}, SessionNotFoundErr
+	}
+	return session, nil
+}
+
+// Touch only rewrites keys that still exist, so a session revoked between
+// the read and the write stays revoked instead of being saved again.
+func (s *redisStore) Touch(ctx context.Context, token string, lastSeen time.Time, ttl time.Duration) (Session, error) {
+	client := s.client.WithContext(ctx)
+
+	session, err := s.get(client, token)
+	if err != nil {
+		return Session{}, err
+	}
+
+	session.LastSeen = lastSeen
+
+	stored, err := json.Marshal(session)
+	if err != nil {
+		return Session{}, err
+	}
+
+	pipe := client.TxPipeline()
+	touched := pipe.SetXX(sessionPrefix+token, stored, ttl)
+	pipe.Expire(indexKey(session.CostumerID), ttl)
+
+	if _, err := pipe.Exec(); err != nil {
+		return Session{}, err
+	}
+	if !touched.Val() {
+		return Session{}, SessionNotFoundErr
+	}
+	return session, nil
+}
+
+func (s *redisStore) Delete(ctx context.Context, token string) error {
+	client := s.client.WithContext(ctx)
+
+	session, err := s.get(client, token)
+	if err == SessionNotFoundErr {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pipe := client.TxPipeline()
+	pipe.Del(sessionPrefix + token)
+	pipe.HDel(indexKey(session.CostumerID), session.ID)
+
+	_, err = pipe.Exec()
+	return err
+}
+
+func (s *redisStore) List(ctx context.Context, costumerID int) ([]Session, error) {
+	client := s.client.WithContext(ctx)
+
+	tokens, err := client.HGetAll(indexKey(costumerID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Session{}
+
+	for id, token := range tokens {
+		session, err := s.get(client, token)
+		if err == SessionNotFoundErr {
+			if err := client.HDel(indexKey(costumerID), id).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created_at.Before(sessions[j].Created_at)
+	})
+
+	return sessions, nil
+}
+
+func (s *redisStore) Revoke(ctx context.Context, costumerID int, id string) error {
+	client := s.client.WithContext(ctx)
+
+	token, err := client.HGet(indexKey(costumerID), id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return SessionNotFoundErr
+		}
+		return err
+	}
+
+	pipe := client.TxPipeline()
+	pipe.Del(sessionPrefix + token)
+	pipe.HDel(indexKey(costumerID), id)
+
+	_, err = pipe.Exec()
+	return err
+}
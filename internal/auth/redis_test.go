@@ -0,0 +1,42 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/auth"
+	"github.com/FelipeMCassiano/urubu_bank/internal/testutil"
+)
+
+func TestTouchDoesNotResurrectRevokedSession(t *testing.T) {
+	client := testutil.Redis(t)
+	store := auth.NewRedisStore(client)
+	ctx := context.Background()
+
+	session := auth.Session{ID: "s1", CostumerID: 7, Created_at: time.Now(), LastSeen: time.Now()}
+	if err := store.Save(ctx, "token", session, time.Minute); err != nil {
+		t.Fatalf("saving session: %v", err)
+	}
+
+	if _, err := store.Touch(ctx, "token", time.Now(), time.Minute); err != nil {
+		t.Fatalf("touching live session: %v", err)
+	}
+
+	if err := store.Revoke(ctx, session.CostumerID, session.ID); err != nil {
+		t.Fatalf("revoking session: %v", err)
+	}
+
+	if _, err := store.Touch(ctx, "token", time.Now(), time.Minute); !errors.Is(err, auth.SessionNotFoundErr) {
+		t.Fatalf("touching revoked session: got %v, want %v", err, auth.SessionNotFoundErr)
+	}
+
+	keys, err := client.Keys("session*").Result()
+	if err != nil {
+		t.Fatalf("listing keys: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("revoked session left keys behind: %v", keys)
+	}
+}
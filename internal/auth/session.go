@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/gofrs/uuid"
+)
+
+var SessionNotFoundErr = apperr.New("session_not_found", http.StatusNotFound, "session not found")
+
+// Session is what the API knows about a login. ID identifies it to its
+// owner when listing and revoking; the cookie token is never exposed.
+type Session struct {
+	ID         string    `json:"id"`
+	CostumerID int       `json:"costumer_id"`
+	Created_at time.Time `json:"created_at"`
+	LastSeen   time.Time `json:"last_seen"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// SessionStore keeps sessions by token and indexes them by costumer. Every
+// Save and Touch restarts the session's ttl.
+type SessionStore interface {
+	Save(ctx context.Context, token string, session Session, ttl time.Duration) error
+	Get(ctx context.Context, token string) (Session, error)
+	Touch(ctx context.Context, token string, lastSeen time.Time, ttl time.Duration) (Session, error)
+	Delete(ctx context.Context, token string) error
+	List(ctx context.Context, costumerID int) ([]Session, error)
+	Revoke(ctx context.Context, costumerID int, id string) error
+}
+
+type Sessions interface {
+	Start(ctx context.Context, costumerID int, ip, userAgent string) (string, Session, error)
+	Authenticate(ctx context.Context, token string) (Session, error)
+	End(ctx context.Context, token string) error
+	List(ctx context.Context, costumerID int) ([]Session, error)
+	Revoke(ctx context.Context, costumerID int, id string) error
+}
+
+type sessions struct {
+	store SessionStore
+	ttl   time.Duration
+}
+
+// NewSessions expires sessions after ttl without a request; each
+// authenticated request pushes the expiry back.
+func NewSessions(store SessionStore, ttl time.Duration) Sessions {
+	return &sessions{store: store, ttl: ttl}
+}
+
+func (s *sessions) Start(ctx context.Context, costumerID int, ip, userAgent string) (string, Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", Session{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", Session{}, err
+	}
+
+	now := time.Now()
+	session := Session{
+		ID:         id.String(),
+		CostumerID: costumerID,
+		Created_at: now,
+		LastSeen:   now,
+		IP:         ip,
+		UserAgent:  userAgent,
+	}
+
+	if err := s.store.Save(ctx, token, session, s.ttl); err != nil {
+		return "", Session{}, err
+	}
+
+	return token, session, nil
+}
+
+func (s *sessions) Authenticate(ctx context.Context, token string) (Session, error) {
+	session, err := s.store.Touch(ctx, token, time.Now(), s.ttl)
+
+	return session, err
+}
+
+func (s *sessions) End(ctx context.Context, token string) error {
+	err := s.store.Delete(ctx, token)
+
+	return err
+}
+
+func (s *sessions) List(ctx context.Context, costumerID int) ([]Session, error) {
+	sessions, err := s.store.List(ctx, costumerID)
+
+	return sessions, err
+}
+
+func (s *sessions) Revoke(ctx context.Context, costumerID int, id string) error {
+	err := s.store.Revoke(ctx, costumerID, id)
+
+	return err
+}
+
+func newToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
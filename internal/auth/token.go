@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	InvalidTokenErr        = apperr.New("invalid_token", http.StatusUnauthorized, "invalid or expired access token")
+	InvalidRefreshTokenErr = apperr.New("invalid_refresh_token", http.StatusUnauthorized, "invalid or expired refresh token")
+)
+
+const (
+	refreshPrefix       = "refresh:"
+	refreshUsedPrefix   = "refresh-used:"
+	familyRevokedPrefix = "refresh-family-revoked:"
+	accessRevokedPrefix = "access-revoked:"
+)
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Access is a verified access token. Family ties it to the chain of refresh
+// tokens it came from, so revoking one revokes the other.
+type Access struct {
+	ID         string
+	CostumerID int
+	Family     string
+	ExpiresAt  time.Time
+}
+
+type accessClaims struct {
+	Family string `json:"fam"`
+	jwt.RegisteredClaims
+}
+
+type refreshRecord struct {
+	CostumerID int    `json:"costumer_id"`
+	Family     string `json:"family"`
+}
+
+type Tokens interface {
+	Issue(ctx context.Context, costumerID int) (TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (TokenPair, error)
+	Verify(ctx context.Context, accessToken string) (Access, error)
+	Revoke(ctx context.Context, access Access) error
+	Keys() JWKS
+}
+
+// tokens issues signed access tokens and opaque refresh tokens that rotate
+// on every use. Presenting a refresh token twice revokes its whole family,
+// which cuts off whoever else holds a copy.
+type tokens struct {
+	keys       *KeyRing
+	store      cache.Store
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokens(keys *KeyRing, store cache.Store, cfg config.AuthConfig) Tokens {
+	return &tokens{
+		keys:       keys,
+		store:      store,
+		issuer:     cfg.Issuer,
+		accessTTL:  cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+	}
+}
+
+func (t *tokens) Issue(ctx context.Context, costumerID int) (TokenPair, error) {
+	family, err := uuid.NewV4()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return t.issue(ctx, costumerID, family.String())
+}
+
+func (t *tokens) issue(ctx context.Context, costumerID int, family string) (TokenPair, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := time.Now()
+	signing := t.keys.Current().signing
+
+	token := jwt.NewWithClaims(signing.method, accessClaims{
+		Family: family,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id.String(),
+			Issuer:    t.issuer,
+			Subject:   strconv.Itoa(costumerID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.accessTTL)),
+		},
+	})
+	token.Header["kid"] = signing.kid
+
+	accessToken, err := token.SignedString(signing.key)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := newToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	record, err := json.Marshal(refreshRecord{CostumerID: costumerID, Family: family})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if err := t.store.Set(ctx, refreshPrefix+hashToken(refreshToken), record, t.refreshTTL); err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(t.accessTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (t *tokens) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	hash := hashToken(refreshToken)
+
+	stored, err := t.store.Get(ctx, refreshPrefix+hash)
+	if err != nil {
+		if errors.Is(err, cache.ErrMiss) {
+			return TokenPair{}, InvalidRefreshTokenErr
+		}
+		return TokenPair{}, err
+	}
+
+	var record refreshRecord
+	if err := json.Unmarshal(stored, &record); err != nil {
+		return TokenPair{}, err
+	}
+
+	revoked, err := t.familyRevoked(ctx, record.Family)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if revoked {
+		return TokenPair{}, InvalidRefreshTokenErr
+	}
+
+	first, err := t.store.SetNX(ctx, refreshUsedPrefix+hash, []byte("1"), t.refreshTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !first {
+		if err := t.revokeFamily(ctx, record.Family); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, InvalidRefreshTokenErr
+	}
+
+	return t.issue(ctx, record.CostumerID, record.Family)
+}
+
+func (t *tokens) Verify(ctx context.Context, accessToken string) (Access, error) {
+	var claims accessClaims
+
+	_, err := jwt.ParseWithClaims(accessToken, &claims, t.key,
+		jwt.WithIssuer(t.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodHS256.Alg()}),
+	)
+	if err != nil {
+		return Access{}, InvalidTokenErr.Wrap(err)
+	}
+
+	costumerID, err := strconv.Atoi(claims.Subject)
+	if err != nil || claims.ID == "" {
+		return Access{}, InvalidTokenErr
+	}
+
+	access := Access{
+		ID:         claims.ID,
+		CostumerID: costumerID,
+		Family:     claims.Family,
+		ExpiresAt:  claims.ExpiresAt.Time,
+	}
+
+	_, err = t.store.Get(ctx, accessRevokedPrefix+access.ID)
+	if err == nil {
+		return Access{}, InvalidTokenErr
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		return Access{}, err
+	}
+
+	revoked, err := t.familyRevoked(ctx, access.Family)
+	if err != nil {
+		return Access{}, err
+	}
+	if revoked {
+		return Access{}, InvalidTokenErr
+	}
+
+	return access, nil
+}
+
+// key resolves the verifying key by kid and insists the token uses that
+// key's algorithm, so a public key can never be used as an HMAC secret.
+func (t *tokens) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := t.keys.Current().verifying[kid]
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("algorithm does not match key")
+	}
+
+	return key.key, nil
+}
+
+// Revoke blocks the access token until it would have expired anyway and
+// ends its refresh family.
+func (t *tokens) Revoke(ctx context.Context, access Access) error {
+	if remaining := time.Until(access.ExpiresAt); remaining > 0 {
+		if err := t.store.Set(ctx, accessRevokedPrefix+access.ID, []byte("1"), remaining); err != nil {
+			return err
+		}
+	}
+
+	return t.revokeFamily(ctx, access.Family)
+}
+
+func (t *tokens) Keys() JWKS {
+	return t.keys.Current().Public()
+}
+
+func (t *tokens) revokeFamily(ctx context.Context, family string) error {
+	return t.store.Set(ctx, familyRevokedPrefix+family, []byte("1"), t.refreshTTL)
+}
+
+func (t *tokens) familyRevoked(ctx context.Context, family string) (bool, error) {
+	_, err := t.store.Get(ctx, familyRevokedPrefix+family)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, cache.ErrMiss) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedStore struct {
+	SessionStore
+}
+
+// NewTracedStore wraps s so every session lookup and write gets a span.
+func NewTracedStore(s SessionStore) SessionStore {
+	return tracedStore{s}
+}
+
+func costumerAttr(id int) attribute.KeyValue {
+	return attribute.Int("client.id", id)
+}
+
+func (s tracedStore) Save(ctx context.Context, token string, session Session, ttl time.Duration) error {
+	ctx, span := tracing.Start(ctx, "sessionStore.Save", costumerAttr(session.CostumerID))
+	err := s.SessionStore.Save(ctx, token, session, ttl)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedStore) Get(ctx context.Context, token string) (Session, error) {
+	ctx, span := tracing.Start(ctx, "sessionStore.Get")
+	session, err := s.SessionStore.Get(ctx, token)
+	tracing.End(span, err)
+	return session, err
+}
+
+func (s tracedStore) Touch(ctx context.Context, token string, lastSeen time.Time, ttl time.Duration) (Session, error) {
+	ctx, span := tracing.Start(ctx, "sessionStore.Touch")
+	session, err := s.SessionStore.Touch(ctx, token, lastSeen, ttl)
+	tracing.End(span, err)
+	return session, err
+}
+
+func (s tracedStore) Delete(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "sessionStore.Delete")
+	err := s.SessionStore.Delete(ctx, token)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedStore) List(ctx context.Context, costumerID int) ([]Session, error) {
+	ctx, span := tracing.Start(ctx, "sessionStore.List", costumerAttr(costumerID))
+	sessions, err := s.SessionStore.List(ctx, costumerID)
+	tracing.End(span, err)
+	return sessions, err
+}
+
+func (s tracedStore) Revoke(ctx context.Context, costumerID int, id string) error {
+	ctx, span := tracing.Start(ctx, "sessionStore.Revoke", costumerAttr(costumerID))
+	err := s.SessionStore.Revoke(ctx, costumerID, id)
+	tracing.End(span, err)
+	return err
+}
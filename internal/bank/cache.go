@@ -0,0 +1,73 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+)
+
+const (
+	idempotencyPrefix = "idempotency:"
+	rateLimitPrefix   = "ratelimit:"
+)
+
+// cacheRepository holds the Respository methods backed by a cache.Store so
+// the Postgres and in-memory repositories share them.
+type cacheRepository struct {
+	store cache.Store
+
+	idempotencyTTL        time.Duration
+	idempotencyPendingTTL time.Duration
+}
+
+func newCacheRepository(store cache.Store, cfg config.Config) cacheRepository {
+	return cacheRepository{
+		store:                 store,
+		idempotencyTTL:        cfg.Idempotency.TTL,
+		idempotencyPendingTTL: cfg.Idempotency.PendingTTL,
+	}
+}
+
+func (r *cacheRepository) ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (bool, error) {
+	pending, err := json.Marshal(domain.IdempotentResponse{RequestHash: requestHash})
+	if err != nil {
+		return false, err
+	}
+
+	return r.store.SetNX(ctx, idempotencyPrefix+key, pending, r.idempotencyPendingTTL)
+}
+
+func (r *cacheRepository) GetIdempotencyKey(ctx context.Context, key string) (domain.IdempotentResponse, error) {
+	var response domain.IdempotentResponse
+
+	stored, err := r.store.Get(ctx, idempotencyPrefix+key)
+	if err != nil {
+		return domain.IdempotentResponse{}, err
+	}
+
+	if err := json.Unmarshal(stored, &response); err != nil {
+		return domain.IdempotentResponse{}, err
+	}
+	return response, nil
+}
+
+func (r *cacheRepository) SaveIdempotencyKey(ctx context.Context, key string, response domain.IdempotentResponse) error {
+	stored, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Set(ctx, idempotencyPrefix+key, stored, r.idempotencyTTL)
+}
+
+func (r *cacheRepository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	return r.store.Del(ctx, idempotencyPrefix+key)
+}
+
+func (r *cacheRepository) IncrementRateLimit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	return r.store.Incr(ctx, rateLimitPrefix+key, window)
+}
@@ -0,0 +1,95 @@
+package bank
+
+import (
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+)
+
+var (
+	InvalidLoginErr    = apperr.New("invalid_login", http.StatusUnprocessableEntity, "login must be an account number, CPF or email")
+	InvalidDocumentErr = apperr.New("invalid_document", http.StatusUnprocessableEntity, "document must be a valid CPF")
+	InvalidEmailErr    = apperr.New("invalid_email", http.StatusUnprocessableEntity, "invalid email")
+	EmailTakenErr      = apperr.New("email_taken", http.StatusConflict, "email already registered")
+	DocumentSetErr     = apperr.New("document_already_set", http.StatusConflict, "account already has a document")
+)
+
+// ParseLogin works out which identifier a costumer typed. A valid CPF wins
+// over an account number, so "123.456.789-09" and "12345678909" both log in
+// by document while "000042" logs into account 42.
+func ParseLogin(login string) (domain.Login, error) {
+	login = strings.TrimSpace(login)
+
+	if strings.Contains(login, "@") {
+		email, err := normalizeEmail(login)
+		if err != nil {
+			return domain.Login{}, InvalidLoginErr
+		}
+		return domain.Login{Kind: domain.LoginEmail, Value: email}, nil
+	}
+
+	if document := documentMarks.Replace(login); validCPF(document) {
+		return domain.Login{Kind: domain.LoginDocument, Value: document}, nil
+	}
+
+	if account, err := strconv.Atoi(login); err == nil && account > 0 {
+		return domain.Login{Kind: domain.LoginAccount, Value: strconv.Itoa(account)}, nil
+	}
+
+	return domain.Login{}, InvalidLoginErr
+}
+
+// normalizeCredentials puts the document and email of a new account in the
+// form they are stored and looked up in.
+func normalizeCredentials(client domain.CreateCostumer) (domain.CreateCostumer, error) {
+	client.Document = documentMarks.Replace(strings.TrimSpace(client.Document))
+	if !validCPF(client.Document) {
+		return domain.CreateCostumer{}, InvalidDocumentErr
+	}
+
+	if client.Email != "" {
+		email, err := normalizeEmail(client.Email)
+		if err != nil {
+			return domain.CreateCostumer{}, err
+		}
+		client.Email = email
+	}
+
+	return client, nil
+}
+
+// normalizeCredentialsUpdate is normalizeCredentials for an update, where
+// either identifier may be missing.
+func normalizeCredentialsUpdate(credentials domain.Credentials) (domain.Credentials, error) {
+	if credentials.Document != "" {
+		credentials.Document = documentMarks.Replace(strings.TrimSpace(credentials.Document))
+		if !validCPF(credentials.Document) {
+			return domain.Credentials{}, InvalidDocumentErr
+		}
+	}
+
+	if credentials.Email != "" {
+		email, err := normalizeEmail(credentials.Email)
+		if err != nil {
+			return domain.Credentials{}, err
+		}
+		credentials.Email = email
+	}
+
+	return credentials, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", InvalidEmailErr
+	}
+
+	return strings.ToLower(email), nil
+}
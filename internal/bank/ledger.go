@@ -0,0 +1,91 @@
+package bank
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	debitEntry  = "d"
+	creditEntry = "c"
+)
+
+type LedgerAccount struct {
+	ClientID int
+	Name     string
+}
+
+var (
+	DepositsAccount = LedgerAccount{Name: "bank:deposits"}
+	TradingAccount  = LedgerAccount{Name: "bank:urubutrading"}
+)
+
+func ClientAccount(id int) LedgerAccount {
+	return LedgerAccount{ClientID: id, Name: "client"}
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type Ledger struct{}
+
+func NewLedger() *Ledger {
+	return &Ledger{}
+}
+
+// Post records a balanced pair of entries moving amount from debit to credit
+// and keeps the cached clients.balance in step. It must run inside the
+// caller's transaction so the postings commit or roll back together.
+func (l *Ledger) Post(ctx context.Context, tx *sql.Tx, debit, credit LedgerAccount, amount int) (string, error) {
+	transferID, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO ledger_entries (transfer_id, client_id, account, kind, amount) VALUES ($1, $2, $3, $4, $5)")
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	for _, entry := range []struct {
+		account LedgerAccount
+		kind    string
+		delta   int
+	}{
+		{debit, debitEntry, -amount},
+		{credit, creditEntry, amount},
+	} {
+		var clientID sql.NullInt64
+		if entry.account.ClientID != 0 {
+			clientID = sql.NullInt64{Int64: int64(entry.account.ClientID), Valid: true}
+		}
+
+		if _, err := stmt.ExecContext(ctx, transferID.String(), clientID, entry.account.Name, entry.kind, amount); err != nil {
+			return "", err
+		}
+
+		if !clientID.Valid {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE clients SET balance = balance + $2 WHERE id=$1", entry.account.ClientID, entry.delta); err != nil {
+			return "", err
+		}
+	}
+
+	return transferID.String(), nil
+}
+
+func (l *Ledger) Balance(ctx context.Context, q querier, clientID int) (int, error) {
+	var balance int
+	err := q.QueryRowContext(ctx, "SELECT COALESCE(SUM(CASE kind WHEN 'c' THEN amount ELSE -amount END), 0) FROM ledger_entries WHERE client_id=$1", clientID).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,848 @@
+package bank
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofrs/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type memoryClient struct {
+	lockedClient
+	Birth    string
+	Document string
+	Email    string
+	Password string
+	IsAdmin  bool
+}
+
+type scheduledRun struct {
+	ID    int
+	DueAt int64
+}
+
+type memoryTransaction struct {
+	domain.LastTransaction
+	ClientID   int
+	TransferID string
+}
+
+type memoryUrubuKey struct {
+	domain.RegisteredUrubuKey
+	ClientID int
+}
+
+// MemoryRepository is a Respository kept entirely in process memory. It
+// enforces the same limits, balances and key rules as the Postgres
+// repository so the API can be exercised without a database.
+type MemoryRepository struct {
+	cacheRepository
+
+	mu           sync.Mutex
+	bcryptCost   int
+	clients      map[int]*memoryClient
+	transactions []memoryTransaction
+	urubukeys    []memoryUrubuKey
+	scheduled    map[int]*domain.ScheduledTransfer
+	claimed      map[int]bool
+	executed     map[scheduledRun]bool
+	lastID       int
+}
+
+var _ Respository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository(store cache.Store, cfg config.Config) *MemoryRepository {
+	return &MemoryRepository{
+		cacheRepository: newCacheRepository(store, cfg),
+
+		bcryptCost: cfg.Auth.BcryptCost,
+		clients:    map[int]*memoryClient{},
+		scheduled:  map[int]*domain.ScheduledTransfer{},
+		claimed:    map[int]bool{},
+		executed:   map[scheduledRun]bool{},
+	}
+}
+
+// SetAdmin grants or revokes admin rights, which the API has no route for.
+func (r *MemoryRepository) SetAdmin(id int, isAdmin bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[id]
+	if !ok {
+		return ErrNotFound
+	}
+	client.IsAdmin = isAdmin
+
+	return nil
+}
+
+func (r *MemoryRepository) nextID() int {
+	r.lastID++
+	return r.lastID
+}
+
+func (r *MemoryRepository) client(id int) (*memoryClient, error) {
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return client, nil
+}
+
+func (r *MemoryRepository) record(clientID, value int, kind, description, payee, payor, transferID string, completedAt time.Time, reversedID *int) int {
+	id := r.nextID()
+
+	r.transactions = append(r.transactions, memoryTransaction{
+		LastTransaction: domain.LastTransaction{
+			ID:                    id,
+			Value:                 value,
+			Kind:                  kind,
+			Description:           description,
+			Payee:                 payee,
+			Payor:                 payor,
+			Completed_at:          completedAt,
+			ReversedTransactionID: reversedID,
+		},
+		ClientID:   clientID,
+		TransferID: transferID,
+	})
+
+	return id
+}
+
+func newTransferID() (string, error) {
+	transferID, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	return transferID.String(), nil
+}
+
+func (r *MemoryRepository) CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error) {
+	password, _ := bcrypt.GenerateFromPassword([]byte(client.Password), r.bcryptCost)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.clients {
+		if existing.Document == client.Document {
+			return domain.CreatedCostumer{}, CostumerExistsErr
+		}
+		if client.Email != "" && existing.Email == client.Email {
+			return domain.CreatedCostumer{}, EmailTakenErr
+		}
+	}
+
+	id := r.nextID()
+	r.clients[id] = &memoryClient{
+		lockedClient: lockedClient{ID: id, Fullname: client.Fullname, Limit: client.Limit},
+		Birth:        client.Birth,
+		Document:     client.Document,
+		Email:        client.Email,
+		Password:     string(password),
+	}
+
+	return domain.CreatedCostumer{ID: id, Fullname: client.Fullname, Limit: client.Limit}, nil
+}
+
+func (r *MemoryRepository) VerifyIfClientExists(ctx context.Context, id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, err := r.client(id)
+	if err != nil {
+		return "", err
+	}
+
+	return client.Fullname, nil
+}
+
+func (r *MemoryRepository) IsAdmin(ctx context.Context, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[id]
+	if !ok {
+		return false, nil
+	}
+
+	return client.IsAdmin, nil
+}
+
+func (r *MemoryRepository) GetCredentials(ctx context.Context, login domain.Login) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, client := range r.clients {
+		var match bool
+		switch login.Kind {
+		case domain.LoginAccount:
+			match = strconv.Itoa(client.ID) == login.Value
+		case domain.LoginDocument:
+			match = client.Document == login.Value
+		case domain.LoginEmail:
+			match = client.Email != "" && client.Email == login.Value
+		default:
+			return domain.User{}, InvalidLoginErr
+		}
+
+		if match {
+			return domain.User{ID: client.ID, Username: client.Fullname, Password: client.Password}, nil
+		}
+	}
+
+	return domain.User{}, ErrNotFound
+}
+
+func (r *MemoryRepository) UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, err := r.client(clientID)
+	if err != nil {
+		return domain.Credentials{}, err
+	}
+
+	if credentials.Document != "" && client.Document != "" && client.Document != credentials.Document {
+		return domain.Credentials{}, DocumentSetErr
+	}
+
+	for _, existing := range r.clients {
+		if existing.ID == clientID {
+			continue
+		}
+		if credentials.Document != "" && existing.Document == credentials.Document {
+			return domain.Credentials{}, CostumerExistsErr
+		}
+		if credentials.Email != "" && existing.Email == credentials.Email {
+			return domain.Credentials{}, EmailTakenErr
+		}
+	}
+
+	if credentials.Document != "" {
+		client.Document = credentials.Document
+	}
+	if credentials.Email != "" {
+		client.Email = credentials.Email
+	}
+
+	return domain.Credentials{Document: client.Document, Email: client.Email}, nil
+}
+
+func (r *MemoryRepository) SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int, 0, len(r.clients))
+	for id := range r.clients {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var clients []domain.CostumerConsult
+
+	for _, id := range ids {
+		client := r.clients[id]
+		if !strings.Contains(strings.ToLower(client.Fullname), strings.ToLower(name)) {
+			continue
+		}
+
+		consult := domain.CostumerConsult{Fullname: client.Fullname}
+		for _, key := range r.urubukeys {
+			if key.ClientID == id && key.Kind == domain.UrubuKeyRandom {
+				consult.UrubuKey = key.Key
+				break
+			}
+		}
+
+		clients = append(clients, consult)
+	}
+
+	return clients, nil
+}
+
+func (r *MemoryRepository) DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, err := r.client(t.Client_Id)
+	if err != nil {
+		return domain.TransactionResponseCredit{}, err
+	}
+
+	transferID, err := newTransferID()
+	if err != nil {
+		return domain.TransactionResponseCredit{}, err
+	}
+
+	client.Balance += t.Value
+	r.record(client.ID, t.Value, creditEntry, t.Description, "self", "self", transferID, t.Completed_at, nil)
+
+	return domain.TransactionResponseCredit{
+		Newbalance:   client.Balance,
+		Completed_at: t.Completed_at,
+	}, nil
+}
+
+func (r *MemoryRepository) CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.transfer(t)
+}
+
+func (r *MemoryRepository) CreateScheduledTransaction(ctx context.Context, s domain.ScheduledTransfer, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run := scheduledRun{ID: s.ID, DueAt: s.NextRunAt.UnixNano()}
+	if r.executed[run] {
+		return domain.TransactionResponseDebit{}, ScheduledAlreadyRunErr
+	}
+
+	response, err := r.transfer(t)
+	if err != nil {
+		return domain.TransactionResponseDebit{}, err
+	}
+
+	r.executed[run] = true
+
+	return response, nil
+}
+
+func (r *MemoryRepository) transfer(t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	key, ok := r.findUrubuKey(lookupUrubuKey(t.PayeeUrubuKey))
+	if !ok {
+		return domain.TransactionResponseDebit{}, ErrNotFound
+	}
+
+	payor, err := r.client(t.Client_Id)
+	if err != nil {
+		return domain.TransactionResponseDebit{}, err
+	}
+	payee, err := r.client(key.ClientID)
+	if err != nil {
+		return domain.TransactionResponseDebit{}, err
+	}
+
+	newbalance := payor.Balance - t.Value
+
+	if (newbalance + payor.Limit) < 0 {
+		return domain.TransactionResponseDebit{}, LimitErr
+	}
+
+	transferID, err := newTransferID()
+	if err != nil {
+		return domain.TransactionResponseDebit{}, err
+	}
+
+	payor.Balance -= t.Value
+	payee.Balance += t.Value
+
+	r.record(payor.ID, t.Value, debitEntry, t.Description, payee.Fullname, payor.Fullname, transferID, t.Completed_at, nil)
+	r.record(payee.ID, t.Value, creditEntry, t.Description, payee.Fullname, payor.Fullname, transferID, t.Completed_at, nil)
+
+	return domain.TransactionResponseDebit{
+		Description:  t.Description,
+		Value:        t.Value,
+		Kind:         t.Kind,
+		Payor:        payor.Fullname,
+		Payee:        payee.Fullname,
+		Completed_at: t.Completed_at,
+		Balance:      newbalance,
+	}, nil
+}
+
+func (r *MemoryRepository) UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, err := r.client(user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	if client.Balance < value {
+		return 0, BalanceErr
+	}
+
+	client.Balance -= value
+
+	key1, _ := uuid.NewV4()
+	key2, _ := uuid.NewV4()
+
+	var earned domain.ValueTraded
+	if key1.String() == key2.String() {
+		earned = domain.ValueTraded(value * 10)
+		client.Balance += int(earned)
+	}
+
+	return earned, nil
+}
+
+func (r *MemoryRepository) ReverseTransaction(ctx context.Context, clientID, transactionID int, completedAt time.Time) (domain.TransactionReversal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var original *memoryTransaction
+	for i := range r.transactions {
+		if r.transactions[i].ID == transactionID && r.transactions[i].ClientID == clientID {
+			original = &r.transactions[i]
+			break
+		}
+	}
+	if original == nil {
+		return domain.TransactionReversal{}, TransactionNotFoundErr
+	}
+
+	if original.Kind != debitEntry || original.TransferID == "" || original.ReversedTransactionID != nil {
+		return domain.TransactionReversal{}, NotReversibleErr
+	}
+
+	payeeID := 0
+	for _, t := range r.transactions {
+		if t.TransferID == original.TransferID && t.Kind == creditEntry {
+			payeeID = t.ClientID
+			break
+		}
+	}
+	if payeeID == 0 {
+		return domain.TransactionReversal{}, NotReversibleErr
+	}
+
+	for _, t := range r.transactions {
+		if t.ReversedTransactionID != nil && *t.ReversedTransactionID == transactionID {
+			return domain.TransactionReversal{}, AlreadyReversedErr
+		}
+	}
+
+	payor, err := r.client(clientID)
+	if err != nil {
+		return domain.TransactionReversal{}, err
+	}
+	payee, err := r.client(payeeID)
+	if err != nil {
+		return domain.TransactionReversal{}, err
+	}
+
+	value := original.Value
+
+	if payee.ID != payor.ID && payee.Balance-value+payee.Limit < 0 {
+		return domain.TransactionReversal{}, LimitErr
+	}
+
+	transferID, err := newTransferID()
+	if err != nil {
+		return domain.TransactionReversal{}, err
+	}
+
+	payee.Balance -= value
+	payor.Balance += value
+
+	reversedID := transactionID
+	r.record(payee.ID, value, debitEntry, reversalDescription, payor.Fullname, payee.Fullname, transferID, completedAt, &reversedID)
+	payorEntryID := r.record(payor.ID, value, creditEntry, reversalDescription, payor.Fullname, payee.Fullname, transferID, completedAt, &reversedID)
+
+	return domain.TransactionReversal{
+		ID:                    payorEntryID,
+		ReversedTransactionID: transactionID,
+		Value:                 value,
+		Payor:                 payee.Fullname,
+		Payee:                 payor.Fullname,
+		Balance:               payor.Balance,
+		Completed_at:          completedAt,
+	}, nil
+}
+
+func (r *MemoryRepository) GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	balance, err := r.balanceStatement(id)
+	if err != nil {
+		return domain.BankStatemant{}, err
+	}
+
+	transactions := r.statement(id, domain.StatementFilter{
+		From:   filter.From,
+		To:     filter.To,
+		Limit:  filter.Limit + 1,
+		Cursor: filter.Cursor,
+	})
+
+	bankstatement := domain.BankStatemant{
+		Balance:          balance,
+		LastTransactions: transactions,
+	}
+
+	if len(bankstatement.LastTransactions) > filter.Limit {
+		bankstatement.LastTransactions = bankstatement.LastTransactions[:filter.Limit]
+		last := bankstatement.LastTransactions[filter.Limit-1]
+		bankstatement.NextCursor = EncodeStatementCursor(domain.StatementCursor{
+			Completed_at: last.Completed_at,
+			ID:           last.ID,
+		})
+	}
+
+	return bankstatement, nil
+}
+
+func (r *MemoryRepository) StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	balance, err := r.balanceStatement(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sliceStatementStream{
+		balance:      balance,
+		transactions: r.statement(id, filter),
+		next:         -1,
+	}, nil
+}
+
+func (r *MemoryRepository) balanceStatement(id int) (domain.BalanceStatement, error) {
+	client, err := r.client(id)
+	if err != nil {
+		return domain.BalanceStatement{}, err
+	}
+
+	return domain.BalanceStatement{
+		Balance:      client.Balance,
+		Limit:        client.Limit,
+		Completed_at: time.Now(),
+	}, nil
+}
+
+// statement mirrors queryStatement: newest first, bounded by filter.
+func (r *MemoryRepository) statement(id int, filter domain.StatementFilter) []domain.LastTransaction {
+	transactions := []domain.LastTransaction{}
+
+	for _, t := range r.transactions {
+		if t.ClientID != id {
+			continue
+		}
+		if !filter.From.IsZero() && t.Completed_at.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !t.Completed_at.Before(filter.To) {
+			continue
+		}
+		if c := filter.Cursor; c != nil && !(t.Completed_at.Before(c.Completed_at) || t.Completed_at.Equal(c.Completed_at) && t.ID < c.ID) {
+			continue
+		}
+
+		transactions = append(transactions, t.LastTransaction)
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		if !transactions[i].Completed_at.Equal(transactions[j].Completed_at) {
+			return transactions[i].Completed_at.After(transactions[j].Completed_at)
+		}
+		return transactions[i].ID > transactions[j].ID
+	})
+
+	if filter.Limit > 0 && len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+	}
+
+	return transactions
+}
+
+type sliceStatementStream struct {
+	balance      domain.BalanceStatement
+	transactions []domain.LastTransaction
+	next         int
+}
+
+func (s *sliceStatementStream) Balance() domain.BalanceStatement {
+	return s.balance
+}
+
+func (s *sliceStatementStream) Next() bool {
+	s.next++
+	return s.next < len(s.transactions)
+}
+
+func (s *sliceStatementStream) Transaction() (domain.LastTransaction, error) {
+	return s.transactions[s.next], nil
+}
+
+func (s *sliceStatementStream) Err() error {
+	return nil
+}
+
+func (s *sliceStatementStream) Close() error {
+	return nil
+}
+
+func (r *MemoryRepository) findUrubuKey(key string) (memoryUrubuKey, bool) {
+	for _, registered := range r.urubukeys {
+		if string(registered.Key) == key {
+			return registered, true
+		}
+	}
+
+	return memoryUrubuKey{}, false
+}
+
+func (r *MemoryRepository) GenerateUrubuKey(ctx context.Context, id int) (domain.UrubuKey, error) {
+	key, err := NormalizeUrubuKey(domain.UrubuKeyRandom, "")
+	if err != nil {
+		return "", err
+	}
+
+	registered, err := r.RegisterUrubuKey(ctx, id, domain.UrubuKeyRandom, key)
+	if err != nil {
+		return "", err
+	}
+
+	return registered.Key, nil
+}
+
+func (r *MemoryRepository) RegisterUrubuKey(ctx context.Context, clientID int, kind string, key domain.UrubuKey) (domain.RegisteredUrubuKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, err := r.client(clientID)
+	if err != nil {
+		return domain.RegisteredUrubuKey{}, err
+	}
+
+	registeredKeys := 0
+	for _, registered := range r.urubukeys {
+		if registered.ClientID == clientID {
+			registeredKeys++
+		}
+	}
+	if registeredKeys >= MaxUrubuKeysPerClient {
+		return domain.RegisteredUrubuKey{}, UrubuKeyLimitErr
+	}
+
+	if kind == domain.UrubuKeyDocument && client.Document != string(key) {
+		return domain.RegisteredUrubuKey{}, NotOwnDocumentErr
+	}
+
+	if _, taken := r.findUrubuKey(string(key)); taken {
+		return domain.RegisteredUrubuKey{}, UrubuKeyTakenErr
+	}
+
+	registered := domain.RegisteredUrubuKey{Key: key, Kind: kind, Created_at: time.Now()}
+	r.urubukeys = append(r.urubukeys, memoryUrubuKey{RegisteredUrubuKey: registered, ClientID: clientID})
+
+	return registered, nil
+}
+
+func (r *MemoryRepository) ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []domain.RegisteredUrubuKey{}
+	for _, registered := range r.urubukeys {
+		if registered.ClientID == clientID {
+			keys = append(keys, registered.RegisteredUrubuKey)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *MemoryRepository) DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lookup := lookupUrubuKey(string(key))
+
+	for i, registered := range r.urubukeys {
+		if registered.ClientID == clientID && string(registered.Key) == lookup {
+			r.urubukeys = append(r.urubukeys[:i], r.urubukeys[i+1:]...)
+			return nil
+		}
+	}
+
+	return UrubuKeyNotFoundErr
+}
+
+func (r *MemoryRepository) LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered, ok := r.findUrubuKey(lookupUrubuKey(key))
+	if !ok {
+		return domain.UrubuKeyOwner{}, 0, UrubuKeyNotFoundErr
+	}
+
+	client, err := r.client(registered.ClientID)
+	if err != nil {
+		return domain.UrubuKeyOwner{}, 0, err
+	}
+
+	return domain.UrubuKeyOwner{Key: registered.Key, Kind: registered.Kind, Name: client.Fullname}, client.ID, nil
+}
+
+func (r *MemoryRepository) CreateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.client(s.Client_Id); err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+
+	s.ID = r.nextID()
+	s.AnchorAt = s.NextRunAt
+	s.Status = domain.ScheduledActive
+	s.Created_at = time.Now()
+	s.Runs = nil
+
+	r.scheduled[s.ID] = &s
+
+	return s, nil
+}
+
+func (r *MemoryRepository) ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scheduled := []domain.ScheduledTransfer{}
+	for _, transfer := range r.scheduled {
+		if transfer.Client_Id == clientID {
+			listed := *transfer
+			listed.Runs = nil
+			scheduled = append(scheduled, listed)
+		}
+	}
+
+	sort.Slice(scheduled, func(i, j int) bool {
+		if !scheduled[i].NextRunAt.Equal(scheduled[j].NextRunAt) {
+			return scheduled[i].NextRunAt.Before(scheduled[j].NextRunAt)
+		}
+		return scheduled[i].ID < scheduled[j].ID
+	})
+
+	return scheduled, nil
+}
+
+func (r *MemoryRepository) GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transfer, ok := r.scheduled[id]
+	if !ok || transfer.Client_Id != clientID {
+		return domain.ScheduledTransfer{}, ScheduledNotFoundErr
+	}
+
+	scheduled := *transfer
+	scheduled.Runs = nil
+	for i := len(transfer.Runs) - 1; i >= 0; i-- {
+		scheduled.Runs = append(scheduled.Runs, transfer.Runs[i])
+	}
+
+	return scheduled, nil
+}
+
+func (r *MemoryRepository) UpdateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transfer, ok := r.scheduled[s.ID]
+	if !ok || transfer.Client_Id != s.Client_Id || transfer.Status != domain.ScheduledActive {
+		return domain.ScheduledTransfer{}, ScheduledNotFoundErr
+	}
+
+	transfer.Value = s.Value
+	transfer.Description = s.Description
+	transfer.PayeeUrubuKey = s.PayeeUrubuKey
+	transfer.NextRunAt = s.NextRunAt
+	transfer.AnchorAt = s.NextRunAt
+	transfer.Recurrence = s.Recurrence
+	transfer.EndAt = s.EndAt
+
+	updated := *transfer
+	updated.Runs = nil
+
+	return updated, nil
+}
+
+func (r *MemoryRepository) CancelScheduledTransfer(ctx context.Context, clientID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transfer, ok := r.scheduled[id]
+	if !ok || transfer.Client_Id != clientID || transfer.Status != domain.ScheduledActive {
+		return ScheduledNotFoundErr
+	}
+
+	transfer.Status = domain.ScheduledCancelled
+
+	return nil
+}
+
+// ProcessDueScheduledTransfer claims a due transfer before releasing the
+// lock, since execute calls back into the repository; claimed transfers are
+// skipped by concurrent callers like rows locked FOR UPDATE SKIP LOCKED.
+func (r *MemoryRepository) ProcessDueScheduledTransfer(ctx context.Context, now time.Time, execute func(domain.ScheduledTransfer) error) (bool, error) {
+	r.mu.Lock()
+
+	var due *domain.ScheduledTransfer
+	for _, transfer := range r.scheduled {
+		if transfer.Status != domain.ScheduledActive || transfer.NextRunAt.After(now) || r.claimed[transfer.ID] {
+			continue
+		}
+		if due == nil || transfer.NextRunAt.Before(due.NextRunAt) || transfer.NextRunAt.Equal(due.NextRunAt) && transfer.ID < due.ID {
+			due = transfer
+		}
+	}
+	if due == nil {
+		r.mu.Unlock()
+		return false, nil
+	}
+
+	r.claimed[due.ID] = true
+	scheduled := *due
+	scheduled.Runs = nil
+
+	r.mu.Unlock()
+
+	run := domain.ScheduledTransferRun{RunAt: now, Status: domain.RunSuccess}
+	if err := execute(scheduled); err != nil {
+		run.Status, run.Error = domain.RunFailed, err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.claimed, due.ID)
+
+	run.ID = r.nextID()
+	due.Runs = append(due.Runs, run)
+
+	if due.Status != domain.ScheduledActive {
+		return true, nil
+	}
+
+	next, ok := scheduled.Next(scheduled.NextRunAt)
+	if !ok {
+		due.Status = domain.ScheduledCompleted
+		if run.Status == domain.RunFailed {
+			due.Status = domain.ScheduledFailed
+		}
+		return true, nil
+	}
+
+	due.NextRunAt = next
+
+	return true, nil
+}
@@ -3,196 +3,170 @@ package bank
 import (
 	"context"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"net/http"
 	"time"
 
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
 	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
-	"github.com/go-redis/redis"
 	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Respository interface {
-	GetBankStatement(ctx context.Context, id int) (domain.BankStatemant, error)
+	GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error)
+	StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error)
 	GenerateUrubuKey(ctx context.Context, id int) (domain.UrubuKey, error)
+	RegisterUrubuKey(ctx context.Context, clientID int, kind string, key domain.UrubuKey) (domain.RegisteredUrubuKey, error)
+	ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error)
+	DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error
+	LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, int, error)
+	IncrementRateLimit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
 	SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error)
 	CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error)
 	VerifyIfClientExists(ctx context.Context, id int) (string, error)
-	GetUsernameAndPassword(ctx context.Context, name string) (domain.User, error)
-	CreateSessionToken(sessionName string) (string, error)
-	DeleteSessionToken(sessionName string) error
-	VerifyIfTokenExists(token string) error
-	RetrieveCookies(sessionName string) (string, error)
-	DeposityMoney(ctx context.Context, t domain.TransactionCredit, result chan domain.TransactionResponseCredit, errChan chan error)
-	CreateTransaction(ctx context.Context, t domain.TransactionDebit, result chan domain.TransactionResponseDebit, errChan chan error)
-	UrubuTrading(ctx context.Context, user domain.User, value int, result chan domain.ValueTraded, errChan chan error)
+	IsAdmin(ctx context.Context, id int) (bool, error)
+	GetCredentials(ctx context.Context, login domain.Login) (domain.User, error)
+	UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error)
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (bool, error)
+	GetIdempotencyKey(ctx context.Context, key string) (domain.IdempotentResponse, error)
+	SaveIdempotencyKey(ctx context.Context, key string, response domain.IdempotentResponse) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
+	DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error)
+	CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error)
+	CreateScheduledTransaction(ctx context.Context, s domain.ScheduledTransfer, t domain.TransactionDebit) (domain.TransactionResponseDebit, error)
+	UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error)
+	ReverseTransaction(ctx context.Context, clientID, transactionID int, completedAt time.Time) (domain.TransactionReversal, error)
+	CreateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error)
+	GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error)
+	UpdateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error)
+	CancelScheduledTransfer(ctx context.Context, clientID, id int) error
+	ProcessDueScheduledTransfer(ctx context.Context, now time.Time, execute func(domain.ScheduledTransfer) error) (bool, error)
 }
 
 type repository struct {
-	db    *sql.DB
-	redis *redis.Client
+	cacheRepository
+
+	db     *sql.DB
+	ledger *Ledger
+	auth   config.AuthConfig
+	logger *slog.Logger
 }
 
-func NewRepository(db *sql.DB, redis *redis.Client) Respository {
+func NewRepository(db *sql.DB, store cache.Store, cfg config.Config, logger *slog.Logger) Respository {
 	return &repository{
-		db:    db,
-		redis: redis,
+		cacheRepository: newCacheRepository(store, cfg),
+
+		db:     db,
+		ledger: NewLedger(),
+		auth:   cfg.Auth,
+		logger: logger,
 	}
 }
 
 var (
-	ErrNotFound = errors.New("client not found")
-	LimitErr    = errors.New("limit error")
-	BalanceErr  = errors.New("value bigger than balance")
+	ErrNotFound       = apperr.New("client_not_found", http.StatusNotFound, "client not found")
+	LimitErr          = apperr.New("limit_exceeded", http.StatusUnprocessableEntity, "limit error")
+	BalanceErr        = apperr.New("insufficient_balance", http.StatusUnprocessableEntity, "value bigger than balance")
+	ForbiddenErr      = apperr.New("forbidden", http.StatusForbidden, "forbidden")
+	CostumerExistsErr = apperr.New("costumer_already_exists", http.StatusConflict, "costumer already exists")
+
+	ScheduledNotFoundErr   = apperr.New("scheduled_transfer_not_found", http.StatusNotFound, "scheduled transfer not found")
+	ScheduledAlreadyRunErr = apperr.New("scheduled_transfer_already_run", http.StatusConflict, "scheduled transfer already ran for this due time")
+
+	TransactionNotFoundErr = apperr.New("transaction_not_found", http.StatusNotFound, "transaction not found")
+	NotReversibleErr       = apperr.New("transaction_not_reversible", http.StatusUnprocessableEntity, "transaction cannot be reversed")
+	AlreadyReversedErr     = apperr.New("transaction_already_reversed", http.StatusConflict, "transaction already reversed")
 )
 
-func (r *repository) VerifyIfTokenExists(token string) error {
-	err := r.redis.Exists(token).Err()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (r *repository) RetrieveCookies(sessionName string) (string, error) {
-	token, err := r.redis.Get(sessionName).Result()
-	if err != nil {
-		return "", err
-	}
-	return token, err
-}
-
-func (r *repository) CreateSessionToken(sessionName string) (string, error) {
-	uuiD, _ := uuid.NewV4()
-	token := base64.URLEncoding.EncodeToString([]byte(uuiD.String()))
-	err := r.redis.Set(sessionName, token, 24*time.Hour).Err()
-	if err != nil {
-		return "", err
-	}
-
-	return token, nil
-}
-
-func (r *repository) DeleteSessionToken(sessionName string) error {
-	err := r.redis.Del(sessionName).Err()
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *repository) UrubuTrading(ctx context.Context, user domain.User, value int, result chan domain.ValueTraded, errChan chan error) {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		errChan <- err
-		return
-	}
-	defer tx.Rollback()
-	var balance int
-
-	err = r.db.QueryRowContext(context.Background(), "SELECT balance FROM clients WHERE fullname=$1 FOR UPDATE", user.Username).Scan(&balance)
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
-	}
+const reversalDescription = "reversal"
 
-	if balance < value {
-		_ = tx.Rollback()
-		errChan <- BalanceErr
-		return
-	}
+const insertTransaction = "INSERT INTO transactions (client_id, value, kind, description, payee, payor, transfer_id, completed_at) VALUES($1,$2,$3,$4,$5,$6,$7,$8)"
 
-	key1, _ := uuid.NewV4()
-	key2, _ := uuid.NewV4()
+const insertReversal = "INSERT INTO transactions (client_id, value, kind, description, payee, payor, transfer_id, completed_at, reversed_transaction_id) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id"
 
-	var newbalance int
+func (r *repository) UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error) {
+	var earned domain.ValueTraded
 
-	if key1.String() != key2.String() {
-		newbalance = balance - value
-		log.Println("pass 1")
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		earned = 0
 
-		stmt, err := r.db.PrepareContext(context.Background(), "UPDATE clients SET balance= $1 WHERE fullname=$2")
+		clients, err := lockClients(ctx, tx, user.ID)
 		if err != nil {
-			_ = tx.Rollback()
-			errChan <- err
-			return
+			return err
 		}
-		if _, err := stmt.ExecContext(context.Background(), newbalance, user.Username); err != nil {
-			_ = tx.Rollback()
-			errChan <- err
-			return
 
+		if clients[user.ID].Balance < value {
+			return BalanceErr
 		}
 
-		if err := tx.Commit(); err != nil {
-			_ = tx.Rollback()
-			errChan <- err
-			return
+		if _, err := r.ledger.Post(ctx, tx, ClientAccount(user.ID), TradingAccount, value); err != nil {
+			return err
 		}
-		log.Println("pass 1")
 
-		result <- 0
+		key1, _ := uuid.NewV4()
+		key2, _ := uuid.NewV4()
 
-		return
-	}
-
-	if key1.String() == key2.String() {
-		newbalance = value * 10
+		if key1.String() == key2.String() {
+			earned = domain.ValueTraded(value * 10)
 
-		stmt, err := r.db.PrepareContext(context.Background(), "UPDATE clients SET balance= $1 WHERE fullname=$2")
-		if err != nil {
-			_ = tx.Rollback()
-			errChan <- err
-			return
+			if _, err := r.ledger.Post(ctx, tx, TradingAccount, ClientAccount(user.ID), int(earned)); err != nil {
+				return err
+			}
 		}
-		if _, err := stmt.ExecContext(context.Background(), newbalance, user.Password); err != nil {
-			_ = tx.Rollback()
-			errChan <- err
-			return
 
-		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
 
-		if err := tx.Commit(); err != nil {
-			_ = tx.Rollback()
-			errChan <- err
-			return
-		}
+	return earned, nil
+}
 
-		result <- domain.ValueTraded(newbalance)
-		return
+// loginColumns maps each kind of login to the credentials column it is
+// looked up by.
+var loginColumns = map[string]string{
+	domain.LoginAccount:  "cr.client_id",
+	domain.LoginDocument: "cr.document",
+	domain.LoginEmail:    "cr.email",
+}
 
-	}
+func (r *repository) GetCredentials(ctx context.Context, login domain.Login) (domain.User, error) {
+	var user domain.User
 
-	if err := tx.Commit(); err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
+	column, ok := loginColumns[login.Kind]
+	if !ok {
+		return domain.User{}, InvalidLoginErr
 	}
-}
 
-func (r *repository) GetUsernameAndPassword(ctx context.Context, name string) (domain.User, error) {
-	var user domain.User
+	cacheKey := "credentials:" + login.Kind + ":" + login.Value
 
-	userRedis, err := r.redis.Get(name).Result()
-	if err != nil && err != redis.Nil {
+	userCache, err := r.store.Get(ctx, cacheKey)
+	if err != nil && err != cache.ErrMiss {
 		return domain.User{}, err
 	}
 
-	if userRedis != "" {
+	if len(userCache) > 0 {
 		userCached := new(domain.User)
-		if err := json.Unmarshal([]byte(userRedis), &userCached); err != nil {
+		if err := json.Unmarshal(userCache, &userCached); err != nil {
 			return domain.User{}, err
 		}
 		return *userCached, nil
 	}
 
-	err = r.db.QueryRowContext(ctx, "SELECT fullname, password FROM clients WHERE fullname=$1", name).Scan(&user.Username, &user.Password)
+	err = r.db.QueryRowContext(ctx, "SELECT c.id, c.fullname, cr.password FROM credentials cr JOIN clients c ON c.id = cr.client_id WHERE "+column+"=$1", login.Value).
+		Scan(&user.ID, &user.Username, &user.Password)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.User{}, ErrNotFound
+		}
 		return domain.User{}, err
 	}
 
@@ -201,15 +175,64 @@ func (r *repository) GetUsernameAndPassword(ctx context.Context, name string) (d
 		return domain.User{}, err
 	}
 
-	if err := r.redis.Set(user.Username, userJson, 72*time.Hour).Err(); err != nil {
+	if err := r.store.Set(ctx, cacheKey, userJson, r.auth.UserCacheTTL); err != nil {
 		return domain.User{}, err
 	}
 
 	return user, nil
 }
 
+// UpdateCredentials sets the document and email a costumer logs in with.
+// The document can only be set once, so accounts opened before credentials
+// existed can add theirs but nobody can swap it for another CPF.
+func (r *repository) UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error) {
+	var previous, updated domain.Credentials
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var document, email sql.NullString
+
+		err := tx.QueryRowContext(ctx, "SELECT document, email FROM credentials WHERE client_id=$1 FOR UPDATE", clientID).Scan(&document, &email)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+		previous = domain.Credentials{Document: document.String, Email: email.String}
+
+		if credentials.Document != "" && document.Valid && document.String != credentials.Document {
+			return DocumentSetErr
+		}
+
+		return tx.QueryRowContext(ctx, "UPDATE credentials SET document=COALESCE(NULLIF($2, ''), document), email=COALESCE(NULLIF($3, ''), email) WHERE client_id=$1 RETURNING COALESCE(document, ''), COALESCE(email, '')",
+			clientID, credentials.Document, credentials.Email).Scan(&updated.Document, &updated.Email)
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			if pqErr.Constraint == "credentials_email_key" {
+				return domain.Credentials{}, EmailTakenErr
+			}
+			return domain.Credentials{}, CostumerExistsErr
+		}
+		return domain.Credentials{}, err
+	}
+
+	// GetCredentials caches by login, so the old email would keep working
+	// until the entry expired.
+	if previous.Email != "" && previous.Email != updated.Email {
+		if err := r.store.Del(ctx, "credentials:"+domain.LoginEmail+":"+previous.Email); err != nil {
+			return domain.Credentials{}, err
+		}
+	}
+
+	return updated, nil
+}
+
 func (r *repository) SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error) {
-	result, err := r.db.QueryContext(ctx, "SElECT fullname, urubukey FROM clients WHERE fullname ILIKE '%' || $1 || '%'", name)
+	result, err := r.db.QueryContext(ctx, `SELECT c.fullname, COALESCE(k.key, '') FROM clients c
+		LEFT JOIN LATERAL (SELECT key FROM urubu_keys WHERE client_id = c.id AND kind = 'random' ORDER BY id LIMIT 1) k ON true
+		WHERE c.fullname ILIKE '%' || $1 || '%'`, name)
 	if err != nil {
 		return []domain.CostumerConsult{}, err
 	}
@@ -238,214 +261,297 @@ func (r *repository) VerifyIfClientExists(ctx context.Context, id int) (string,
 	var clientxists string
 	err := r.db.QueryRowContext(ctx, "SELECT fullname FROM clients WHERE id=$1", id).Scan(&clientxists)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
 		return "", err
 	}
 
 	return clientxists, nil
 }
 
-func (r *repository) DeposityMoney(ctx context.Context, t domain.TransactionCredit, result chan domain.TransactionResponseCredit, errChan chan error) {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
-	}
-	defer tx.Rollback()
-	var limit, balance int
-
-	err = tx.QueryRowContext(context.Background(), "SELECT credit_limit, balance FROM clients WHERE id=$1 FOR UPDATE", t.Client_Id).Scan(&limit, &balance)
+func (r *repository) IsAdmin(ctx context.Context, id int) (bool, error) {
+	var isAdmin bool
+	err := r.db.QueryRowContext(ctx, "SELECT is_admin FROM clients WHERE id=$1", id).Scan(&isAdmin)
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
-	}
-	newbalance := balance + t.Value
-	stmt1, err := tx.PrepareContext(context.Background(), "INSERT INTO transactions (client_id, value, kind, description, payee, completed_at) VALUES($1,$2,$3,$4,$5,$6)")
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
+		// A session or token can outlive its costumer, who is no admin.
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
 	}
-	defer stmt1.Close()
-
-	log.Println(newbalance)
 
-	_, err = stmt1.ExecContext(context.Background(), t.Client_Id, t.Value, t.Kind, t.Description, "self", t.Completed_at)
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
+	return isAdmin, nil
+}
 
-		return
-	}
+func (r *repository) DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error) {
+	var response domain.TransactionResponseCredit
 
-	stmt2, err := tx.PrepareContext(context.Background(), "UPDATE clients SET balance=$2 WHERE id=$1")
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
-	}
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		clients, err := lockClients(ctx, tx, t.Client_Id)
+		if err != nil {
+			return err
+		}
+		newbalance := clients[t.Client_Id].Balance + t.Value
 
-	_, err = stmt2.ExecContext(context.Background(), t.Client_Id, newbalance)
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
+		transferID, err := r.ledger.Post(ctx, tx, DepositsAccount, ClientAccount(t.Client_Id), t.Value)
+		if err != nil {
+			return err
+		}
 
-		return
-	}
+		_, err = tx.ExecContext(ctx, insertTransaction, t.Client_Id, t.Value, creditEntry, t.Description, "self", "self", transferID, t.Completed_at)
+		if err != nil {
+			return err
+		}
 
-	response := domain.TransactionResponseCredit{
-		Newbalance:   newbalance,
-		Completed_at: t.Completed_at,
-	}
+		response = domain.TransactionResponseCredit{
+			Newbalance:   newbalance,
+			Completed_at: t.Completed_at,
+		}
 
-	if err := tx.Commit(); err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-		return
+		return nil
+	})
+	if err != nil {
+		return domain.TransactionResponseCredit{}, err
 	}
 
-	result <- response
+	return response, nil
 }
 
-func (r *repository) CreateTransaction(ctx context.Context, t domain.TransactionDebit, result chan domain.TransactionResponseDebit, errChan chan error) {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		errChan <- err
-		return
-	}
-	defer tx.Rollback()
-	var limit, balance int
+func (r *repository) CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	var response domain.TransactionResponseDebit
 
-	err = tx.QueryRowContext(context.Background(), "SELECT credit_limit, balance FROM clients WHERE fullname=$1", t.Payor).Scan(&limit, &balance)
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		response, err = r.transfer(ctx, tx, t)
+		return err
+	})
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-
-		return
+		return domain.TransactionResponseDebit{}, err
 	}
 
-	log.Println(balance)
-
-	newbalance := balance - t.Value
-	log.Println(newbalance)
+	return response, nil
+}
 
-	if (newbalance + limit) < 0 {
-		_ = tx.Rollback()
-		errChan <- LimitErr
+// CreateScheduledTransaction makes the transfer due from s at s.NextRunAt and
+// marks that run executed in the same transaction, so it can never be paid
+// twice however the recording of the run fails afterwards.
+func (r *repository) CreateScheduledTransaction(ctx context.Context, s domain.ScheduledTransfer, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	var response domain.TransactionResponseDebit
 
-		return
-	}
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "INSERT INTO scheduled_transfer_executions (scheduled_transfer_id, due_at) VALUES ($1, $2) ON CONFLICT DO NOTHING", s.ID, s.NextRunAt)
+		if err != nil {
+			return err
+		}
 
-	var Payee string
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted == 0 {
+			return ScheduledAlreadyRunErr
+		}
 
-	err = tx.QueryRowContext(context.Background(), "SELECT fullname FROM clients WHERE urubukey=$1", t.PayeeUrubuKey).Scan(&Payee)
+		response, err = r.transfer(ctx, tx, t)
+		return err
+	})
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-
-		return
+		return domain.TransactionResponseDebit{}, err
 	}
 
-	stmt1, err := tx.PrepareContext(context.Background(), "INSERT INTO transactions (client_id, value, kind, description, payee, completed_at) VALUES($1,$2,$3,$4,$5,$6)")
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
+	return response, nil
+}
 
-		return
-	}
+func (r *repository) transfer(ctx context.Context, tx *sql.Tx, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	var payeeID int
 
-	_, err = stmt1.ExecContext(context.Background(), t.Client_Id, t.Value, t.Kind, t.Description, Payee, t.Completed_at)
+	err := tx.QueryRowContext(ctx, "SELECT client_id FROM urubu_keys WHERE key=$1", lookupUrubuKey(t.PayeeUrubuKey)).Scan(&payeeID)
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-
-		return
+		if err == sql.ErrNoRows {
+			return domain.TransactionResponseDebit{}, ErrNotFound
+		}
+		return domain.TransactionResponseDebit{}, err
 	}
 
-	stmt2, err := tx.PrepareContext(context.Background(), "UPDATE clients SET balance=$2 WHERE fullname=$1")
+	clients, err := lockClients(ctx, tx, t.Client_Id, payeeID)
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-
-		return
+		return domain.TransactionResponseDebit{}, err
 	}
+	payor, payee := clients[t.Client_Id], clients[payeeID]
 
-	_, err = stmt2.ExecContext(context.Background(), t.Payor, newbalance)
-	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
+	newbalance := payor.Balance - t.Value
 
-		return
+	if (newbalance + payor.Limit) < 0 {
+		return domain.TransactionResponseDebit{}, LimitErr
 	}
 
-	stmt3, err := tx.PrepareContext(context.Background(), "UPDATE clients SET balance = balance + $2 WHERE urubukey=$1")
+	transferID, err := r.ledger.Post(ctx, tx, ClientAccount(payor.ID), ClientAccount(payee.ID), t.Value)
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-
-		return
+		return domain.TransactionResponseDebit{}, err
 	}
 
-	_, err = stmt3.ExecContext(context.Background(), t.PayeeUrubuKey, t.Value)
+	stmt, err := tx.PrepareContext(ctx, insertTransaction)
 	if err != nil {
-		_ = tx.Rollback()
-		errChan <- err
-
-		return
+		return domain.TransactionResponseDebit{}, err
 	}
+	defer stmt.Close()
 
-	defer stmt1.Close()
-	defer stmt2.Close()
-	defer stmt3.Close()
-
-	err = tx.Commit()
+	_, err = stmt.ExecContext(ctx, payor.ID, t.Value, debitEntry, t.Description, payee.Fullname, payor.Fullname, transferID, t.Completed_at)
 	if err != nil {
-		if err.Error() == "no rows in result set" {
-			_ = tx.Rollback()
-			errChan <- ErrNotFound
-
-			return
-		}
-		errChan <- err
+		return domain.TransactionResponseDebit{}, err
+	}
 
-		return
+	_, err = stmt.ExecContext(ctx, payee.ID, t.Value, creditEntry, t.Description, payee.Fullname, payor.Fullname, transferID, t.Completed_at)
+	if err != nil {
+		return domain.TransactionResponseDebit{}, err
 	}
 
-	response := domain.TransactionResponseDebit{
+	return domain.TransactionResponseDebit{
 		Description:  t.Description,
 		Value:        t.Value,
 		Kind:         t.Kind,
-		Payor:        t.Payor,
-		Payee:        Payee,
+		Payor:        payor.Fullname,
+		Payee:        payee.Fullname,
 		Completed_at: t.Completed_at,
 		Balance:      newbalance,
+	}, nil
+}
+
+func (r *repository) ReverseTransaction(ctx context.Context, clientID, transactionID int, completedAt time.Time) (domain.TransactionReversal, error) {
+	var response domain.TransactionReversal
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var value int
+		var kind string
+		var transferID sql.NullString
+		var reversedID sql.NullInt64
+
+		err := tx.QueryRowContext(ctx, "SELECT value, kind, transfer_id, reversed_transaction_id FROM transactions WHERE id=$1 AND client_id=$2", transactionID, clientID).
+			Scan(&value, &kind, &transferID, &reversedID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return TransactionNotFoundErr
+			}
+			return err
+		}
+
+		if kind != debitEntry || !transferID.Valid || reversedID.Valid {
+			return NotReversibleErr
+		}
+
+		var payeeID int
+
+		err = tx.QueryRowContext(ctx, "SELECT client_id FROM transactions WHERE transfer_id=$1 AND kind=$2", transferID.String, creditEntry).Scan(&payeeID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return NotReversibleErr
+			}
+			return err
+		}
+
+		clients, err := lockClients(ctx, tx, clientID, payeeID)
+		if err != nil {
+			return err
+		}
+		payor, payee := clients[clientID], clients[payeeID]
+
+		var reversed bool
+
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM transactions WHERE reversed_transaction_id=$1)", transactionID).Scan(&reversed)
+		if err != nil {
+			return err
+		}
+		if reversed {
+			return AlreadyReversedErr
+		}
+
+		if payee.ID != payor.ID && payee.Balance-value+payee.Limit < 0 {
+			return LimitErr
+		}
+
+		reversalTransferID, err := r.ledger.Post(ctx, tx, ClientAccount(payee.ID), ClientAccount(payor.ID), value)
+		if err != nil {
+			return err
+		}
+
+		stmt, err := tx.PrepareContext(ctx, insertReversal)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		var payeeEntryID, payorEntryID int
+
+		err = stmt.QueryRowContext(ctx, payee.ID, value, debitEntry, reversalDescription, payor.Fullname, payee.Fullname, reversalTransferID, completedAt, transactionID).Scan(&payeeEntryID)
+		if err != nil {
+			return err
+		}
+
+		err = stmt.QueryRowContext(ctx, payor.ID, value, creditEntry, reversalDescription, payor.Fullname, payee.Fullname, reversalTransferID, completedAt, transactionID).Scan(&payorEntryID)
+		if err != nil {
+			return err
+		}
+
+		balance := payor.Balance + value
+		if payee.ID == payor.ID {
+			balance = payor.Balance
+		}
+
+		response = domain.TransactionReversal{
+			ID:                    payorEntryID,
+			ReversedTransactionID: transactionID,
+			Value:                 value,
+			Payor:                 payee.Fullname,
+			Payee:                 payor.Fullname,
+			Balance:               balance,
+			Completed_at:          completedAt,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return domain.TransactionReversal{}, err
 	}
 
-	result <- response
-	return
+	return response, nil
 }
 
 func (r *repository) CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		_ = tx.Rollback()
 		return domain.CreatedCostumer{}, err
 	}
-	password, _ := bcrypt.GenerateFromPassword([]byte(client.Password), 10)
+	password, _ := bcrypt.GenerateFromPassword([]byte(client.Password), r.auth.BcryptCost)
 
 	createdClient := domain.CreatedCostumer{
 		Fullname: client.Fullname,
 		Limit:    client.Limit,
 	}
 
-	log.Println("Inserting client:", client)
+	r.logger.InfoContext(ctx, "creating client", "client", client)
 	var id int
 
-	err = tx.QueryRowContext(context.Background(), "INSERT INTO clients (fullname, birth, credit_limit, password) VALUES ($1, $2, $3, $4) RETURNING id",
-		client.Fullname, client.Birth, client.Limit, string(password)).Scan(&id)
+	err = tx.QueryRowContext(ctx, "INSERT INTO clients (fullname, birth, credit_limit) VALUES ($1, $2, $3) RETURNING id",
+		client.Fullname, client.Birth, client.Limit).Scan(&id)
+	if err != nil {
+		_ = tx.Rollback()
+		return domain.CreatedCostumer{}, err
+	}
+
+	email := sql.NullString{String: client.Email, Valid: client.Email != ""}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO credentials (client_id, document, email, password) VALUES ($1, $2, $3, $4)",
+		id, client.Document, email, string(password))
 	if err != nil {
 		_ = tx.Rollback()
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			if pqErr.Constraint == "credentials_email_key" {
+				return domain.CreatedCostumer{}, EmailTakenErr
+			}
+			return domain.CreatedCostumer{}, CostumerExistsErr
+		}
 		return domain.CreatedCostumer{}, err
 	}
 
@@ -460,80 +566,171 @@ func (r *repository) CreateNewAccount(ctx context.Context, client domain.CreateC
 }
 
 func (r *repository) GenerateUrubuKey(ctx context.Context, id int) (domain.UrubuKey, error) {
-	tx, err := r.db.BeginTx(ctx, nil)
+	key, err := NormalizeUrubuKey(domain.UrubuKeyRandom, "")
 	if err != nil {
 		return "", err
 	}
-	defer tx.Rollback()
-	log.Println("comecei GenerateUrubuKey")
 
-	urubukeygeneratedU, err := uuid.NewV4()
+	registered, err := r.RegisterUrubuKey(ctx, id, domain.UrubuKeyRandom, key)
 	if err != nil {
 		return "", err
 	}
 
-	urubukeygenerated := urubukeygeneratedU.String()
+	return registered.Key, nil
+}
 
-	stmt, err := tx.PrepareContext(context.Background(), "UPDATE clients SET urubukey=$2 WHERE id =$1")
+func (r *repository) RegisterUrubuKey(ctx context.Context, clientID int, kind string, key domain.UrubuKey) (domain.RegisteredUrubuKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", err
+		return domain.RegisteredUrubuKey{}, err
 	}
+	defer tx.Rollback()
 
-	defer stmt.Close()
+	if _, err := lockClients(ctx, tx, clientID); err != nil {
+		return domain.RegisteredUrubuKey{}, err
+	}
 
-	_, err = stmt.Exec(id, urubukeygenerated)
+	var registeredKeys int
+
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM urubu_keys WHERE client_id=$1", clientID).Scan(&registeredKeys)
 	if err != nil {
-		return "", err
+		return domain.RegisteredUrubuKey{}, err
+	}
+	if registeredKeys >= MaxUrubuKeysPerClient {
+		return domain.RegisteredUrubuKey{}, UrubuKeyLimitErr
+	}
+
+	if kind == domain.UrubuKeyDocument {
+		var document sql.NullString
+
+		err = tx.QueryRowContext(ctx, "SELECT document FROM credentials WHERE client_id=$1", clientID).Scan(&document)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return domain.RegisteredUrubuKey{}, err
+		}
+		if document.String != string(key) {
+			return domain.RegisteredUrubuKey{}, NotOwnDocumentErr
+		}
+	}
+
+	registered := domain.RegisteredUrubuKey{Key: key, Kind: kind}
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO urubu_keys (client_id, kind, key) VALUES ($1, $2, $3) RETURNING created_at", clientID, kind, key).Scan(&registered.Created_at)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return domain.RegisteredUrubuKey{}, UrubuKeyTakenErr
+		}
+		return domain.RegisteredUrubuKey{}, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return "", nil
+		return domain.RegisteredUrubuKey{}, err
 	}
 
-	return domain.UrubuKey(urubukeygenerated), nil
+	return registered, nil
 }
 
-func (r *repository) GetBankStatement(ctx context.Context, id int) (domain.BankStatemant, error) {
-	tx, err := r.db.BeginTx(ctx, nil)
+func (r *repository) ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT key, kind, created_at FROM urubu_keys WHERE client_id=$1 ORDER BY id", clientID)
 	if err != nil {
-		return domain.BankStatemant{}, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []domain.RegisteredUrubuKey{}
+
+	for rows.Next() {
+		var key domain.RegisteredUrubuKey
+		if err := rows.Scan(&key.Key, &key.Kind, &key.Created_at); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *repository) DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM urubu_keys WHERE client_id=$1 AND key=$2", clientID, lookupUrubuKey(string(key)))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return UrubuKeyNotFoundErr
+	}
+
+	return nil
+}
+
+func (r *repository) LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, int, error) {
+	var owner domain.UrubuKeyOwner
+	var clientID int
+
+	err := r.db.QueryRowContext(ctx, "SELECT k.key, k.kind, c.id, c.fullname FROM urubu_keys k JOIN clients c ON c.id = k.client_id WHERE k.key=$1", lookupUrubuKey(key)).
+		Scan(&owner.Key, &owner.Kind, &clientID, &owner.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.UrubuKeyOwner{}, 0, UrubuKeyNotFoundErr
+		}
+		return domain.UrubuKeyOwner{}, 0, err
 	}
-	defer tx.Rollback()
 
-	var balance, credit_limit int
+	return owner, clientID, nil
+}
 
-	err = tx.QueryRowContext(context.Background(), "SELECT balance ,credit_limit FROM clients WHERE id=$1", id).Scan(&balance, &credit_limit)
+func (r *repository) GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
 	if err != nil {
 		return domain.BankStatemant{}, err
 	}
+	defer tx.Rollback()
 
-	balanceAccount := domain.BalanceStatement{
-		Balance:      balance,
-		Limit:        credit_limit,
-		Completed_at: time.Now(),
+	balanceAccount, err := r.balanceStatement(ctx, tx, id)
+	if err != nil {
+		return domain.BankStatemant{}, err
 	}
 
-	rows, err := tx.QueryContext(context.Background(), "SELECT id, value, kind, description, payee, completed_at FROM transactions WHERE client_id=$1", id)
+	rows, err := queryStatement(ctx, tx, id, domain.StatementFilter{
+		From:   filter.From,
+		To:     filter.To,
+		Limit:  filter.Limit + 1,
+		Cursor: filter.Cursor,
+	})
 	if err != nil {
 		return domain.BankStatemant{}, err
 	}
+	defer rows.Close()
+
 	bankstatement := domain.BankStatemant{
 		Balance:          balanceAccount,
 		LastTransactions: []domain.LastTransaction{},
 	}
 
-	if rows != nil {
-		for rows.Next() {
-			var Transaction domain.LastTransaction
-			err := rows.Scan(&Transaction.ID, &Transaction.Value, &Transaction.Kind, &Transaction.Description, &Transaction.Payee, &Transaction.Completed_at)
-			if err != nil {
-				return domain.BankStatemant{}, err
-			}
-			log.Println(Transaction)
+	for rows.Next() {
+		Transaction, err := scanStatementRow(rows)
+		if err != nil {
+			return domain.BankStatemant{}, err
+		}
 
-			bankstatement.LastTransactions = append(bankstatement.LastTransactions, Transaction)
+		bankstatement.LastTransactions = append(bankstatement.LastTransactions, Transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.BankStatemant{}, err
+	}
 
-		}
+	if len(bankstatement.LastTransactions) > filter.Limit {
+		bankstatement.LastTransactions = bankstatement.LastTransactions[:filter.Limit]
+		last := bankstatement.LastTransactions[filter.Limit-1]
+		bankstatement.NextCursor = EncodeStatementCursor(domain.StatementCursor{
+			Completed_at: last.Completed_at,
+			ID:           last.ID,
+		})
 	}
 
 	err = tx.Commit()
@@ -543,3 +740,253 @@ func (r *repository) GetBankStatement(ctx context.Context, id int) (domain.BankS
 
 	return bankstatement, nil
 }
+
+func (r *repository) StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	balanceAccount, err := r.balanceStatement(ctx, tx, id)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	rows, err := queryStatement(ctx, tx, id, filter)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return &sqlStatementStream{
+		balance: balanceAccount,
+		tx:      tx,
+		rows:    rows,
+	}, nil
+}
+
+func (r *repository) balanceStatement(ctx context.Context, tx *sql.Tx, id int) (domain.BalanceStatement, error) {
+	var credit_limit int
+
+	err := tx.QueryRowContext(ctx, "SELECT credit_limit FROM clients WHERE id=$1", id).Scan(&credit_limit)
+	if err != nil {
+		return domain.BalanceStatement{}, err
+	}
+
+	balance, err := r.ledger.Balance(ctx, tx, id)
+	if err != nil {
+		return domain.BalanceStatement{}, err
+	}
+
+	return domain.BalanceStatement{
+		Balance:      balance,
+		Limit:        credit_limit,
+		Completed_at: time.Now(),
+	}, nil
+}
+
+func queryStatement(ctx context.Context, tx *sql.Tx, id int, filter domain.StatementFilter) (*sql.Rows, error) {
+	var from, to, cursorAt sql.NullTime
+	var limit sql.NullInt64
+	var cursorID int
+
+	if !filter.From.IsZero() {
+		from = sql.NullTime{Time: filter.From, Valid: true}
+	}
+	if !filter.To.IsZero() {
+		to = sql.NullTime{Time: filter.To, Valid: true}
+	}
+	if filter.Cursor != nil {
+		cursorAt = sql.NullTime{Time: filter.Cursor.Completed_at, Valid: true}
+		cursorID = filter.Cursor.ID
+	}
+	if filter.Limit > 0 {
+		limit = sql.NullInt64{Int64: int64(filter.Limit), Valid: true}
+	}
+
+	return tx.QueryContext(ctx, `SELECT id, value, kind, description, payee, COALESCE(payor, ''), reversed_transaction_id, completed_at FROM transactions
+		WHERE client_id=$1
+		AND ($2::timestamp IS NULL OR completed_at >= $2)
+		AND ($3::timestamp IS NULL OR completed_at < $3)
+		AND ($4::timestamp IS NULL OR (completed_at, id) < ($4, $5))
+		ORDER BY completed_at DESC, id DESC
+		LIMIT $6`, id, from, to, cursorAt, cursorID, limit)
+}
+
+func scanStatementRow(rows *sql.Rows) (domain.LastTransaction, error) {
+	var Transaction domain.LastTransaction
+	var reversedID sql.NullInt64
+
+	err := rows.Scan(&Transaction.ID, &Transaction.Value, &Transaction.Kind, &Transaction.Description, &Transaction.Payee, &Transaction.Payor, &reversedID, &Transaction.Completed_at)
+	if err != nil {
+		return domain.LastTransaction{}, err
+	}
+
+	if reversedID.Valid {
+		id := int(reversedID.Int64)
+		Transaction.ReversedTransactionID = &id
+	}
+
+	return Transaction, nil
+}
+
+const scheduledColumns = "id, client_id, value, description, payee_urubukey, next_run_at, anchor_at, recurrence, end_at, status, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScheduledTransfer(row rowScanner) (domain.ScheduledTransfer, error) {
+	var scheduled domain.ScheduledTransfer
+	var endAt sql.NullTime
+
+	err := row.Scan(&scheduled.ID, &scheduled.Client_Id, &scheduled.Value, &scheduled.Description, &scheduled.PayeeUrubuKey,
+		&scheduled.NextRunAt, &scheduled.AnchorAt, &scheduled.Recurrence, &endAt, &scheduled.Status, &scheduled.Created_at)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.ScheduledTransfer{}, ScheduledNotFoundErr
+		}
+		return domain.ScheduledTransfer{}, err
+	}
+
+	if endAt.Valid {
+		scheduled.EndAt = &endAt.Time
+	}
+
+	return scheduled, nil
+}
+
+func (r *repository) CreateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	row := r.db.QueryRowContext(ctx, "INSERT INTO scheduled_transfers (client_id, value, description, payee_urubukey, next_run_at, anchor_at, recurrence, end_at) VALUES ($1, $2, $3, $4, $5, $5, $6, $7) RETURNING "+scheduledColumns,
+		s.Client_Id, s.Value, s.Description, s.PayeeUrubuKey, s.NextRunAt, s.Recurrence, s.EndAt)
+
+	return scanScheduledTransfer(row)
+}
+
+func (r *repository) ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+scheduledColumns+" FROM scheduled_transfers WHERE client_id=$1 ORDER BY next_run_at, id", clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled := []domain.ScheduledTransfer{}
+
+	for rows.Next() {
+		transfer, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		scheduled = append(scheduled, transfer)
+	}
+
+	return scheduled, rows.Err()
+}
+
+func (r *repository) GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+scheduledColumns+" FROM scheduled_transfers WHERE client_id=$1 AND id=$2", clientID, id)
+
+	scheduled, err := scanScheduledTransfer(row)
+	if err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, run_at, status, COALESCE(error, '') FROM scheduled_transfer_runs WHERE scheduled_transfer_id=$1 ORDER BY run_at DESC, id DESC", id)
+	if err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run domain.ScheduledTransferRun
+		if err := rows.Scan(&run.ID, &run.RunAt, &run.Status, &run.Error); err != nil {
+			return domain.ScheduledTransfer{}, err
+		}
+
+		scheduled.Runs = append(scheduled.Runs, run)
+	}
+
+	return scheduled, rows.Err()
+}
+
+func (r *repository) UpdateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	row := r.db.QueryRowContext(ctx, "UPDATE scheduled_transfers SET value=$3, description=$4, payee_urubukey=$5, next_run_at=$6, anchor_at=$6, recurrence=$7, end_at=$8 WHERE client_id=$1 AND id=$2 AND status='active' RETURNING "+scheduledColumns,
+		s.Client_Id, s.ID, s.Value, s.Description, s.PayeeUrubuKey, s.NextRunAt, s.Recurrence, s.EndAt)
+
+	return scanScheduledTransfer(row)
+}
+
+func (r *repository) CancelScheduledTransfer(ctx context.Context, clientID, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE scheduled_transfers SET status='cancelled' WHERE client_id=$1 AND id=$2 AND status='active'", clientID, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ScheduledNotFoundErr
+	}
+
+	return nil
+}
+
+// ProcessDueScheduledTransfer claims one due transfer, hands it to execute
+// and records the outcome. The row stays locked until the run is recorded,
+// so concurrent workers skip it; execute is expected to go through
+// CreateScheduledTransaction, which keeps a run that was paid but never
+// recorded from being paid again. The lock is NO KEY UPDATE because that
+// insert's foreign key takes KEY SHARE on the same row from another
+// connection.
+func (r *repository) ProcessDueScheduledTransfer(ctx context.Context, now time.Time, execute func(domain.ScheduledTransfer) error) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, "SELECT "+scheduledColumns+" FROM scheduled_transfers WHERE status='active' AND next_run_at <= $1 ORDER BY next_run_at, id LIMIT 1 FOR NO KEY UPDATE SKIP LOCKED", now)
+
+	scheduled, err := scanScheduledTransfer(row)
+	if err != nil {
+		if err == ScheduledNotFoundErr {
+			return false, nil
+		}
+		return false, err
+	}
+
+	runStatus, runError := domain.RunSuccess, sql.NullString{}
+	if err := execute(scheduled); err != nil {
+		runStatus, runError = domain.RunFailed, sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, run_at, status, error) VALUES ($1, $2, $3, $4)", scheduled.ID, now, runStatus, runError)
+	if err != nil {
+		return false, err
+	}
+
+	status := domain.ScheduledActive
+	next, ok := scheduled.Next(scheduled.NextRunAt)
+	if !ok {
+		next = scheduled.NextRunAt
+		status = domain.ScheduledCompleted
+		if runStatus == domain.RunFailed {
+			status = domain.ScheduledFailed
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE scheduled_transfers SET next_run_at=$2, status=$3 WHERE id=$1", scheduled.ID, next, status)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
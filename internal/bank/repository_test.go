@@ -0,0 +1,284 @@
+package bank_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/FelipeMCassiano/urubu_bank/internal/testutil"
+)
+
+func TestSearchClientByName(t *testing.T) {
+	repo := testutil.Repository(t, testutil.Postgres(t), testutil.Config())
+
+	seeded := testutil.Seed(t, repo,
+		domain.CreateCostumer{Fullname: "Maria Silva", Birth: "01/01/1990", Password: "pw"},
+		domain.CreateCostumer{Fullname: "Maria Silva", Birth: "02/02/1992", Password: "pw"},
+		domain.CreateCostumer{Fullname: "MARIANA Souza", Birth: "03/03/1993", Password: "pw"},
+		domain.CreateCostumer{Fullname: "João Pereira", Birth: "04/04/1994", Password: "pw"},
+	)
+
+	keys := map[domain.UrubuKey]bool{}
+	for _, costumer := range seeded {
+		keys[costumer.UrubuKey] = true
+	}
+
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{"mari", []string{"MARIANA Souza", "Maria Silva", "Maria Silva"}},
+		{"SILVA", []string{"Maria Silva", "Maria Silva"}},
+		{"ão Pe", []string{"João Pereira"}},
+		{"souza", []string{"MARIANA Souza"}},
+		{"nobody", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			found, err := repo.SearchClientByName(context.Background(), tt.search)
+			if err != nil {
+				t.Fatalf("SearchClientByName: %v", err)
+			}
+
+			var names []string
+			for _, costumer := range found {
+				names = append(names, costumer.Fullname)
+				if !keys[costumer.UrubuKey] {
+					t.Errorf("%s has urubukey %q, not the one seeded", costumer.Fullname, costumer.UrubuKey)
+				}
+			}
+			sort.Strings(names)
+
+			if len(names) != len(tt.want) {
+				t.Fatalf("got %v, want %v", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", names, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDepositWaitsForRowLock(t *testing.T) {
+	db := testutil.Postgres(t)
+	repo := testutil.Repository(t, db, testutil.Config())
+	account := testutil.Seed(t, repo, domain.CreateCostumer{Fullname: "Locked", Birth: "01/01/1990", Password: "pw"})[0]
+
+	ctx := context.Background()
+
+	holder, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer holder.Rollback()
+
+	if _, err := holder.ExecContext(ctx, "SELECT id FROM clients WHERE id=$1 FOR UPDATE", account.ID); err != nil {
+		t.Fatalf("locking client: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.DeposityMoney(ctx, domain.TransactionCredit{
+			Client_Id:    account.ID,
+			Value:        100,
+			Kind:         "credit",
+			Description:  "locked",
+			Completed_at: time.Now(),
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("deposit finished while the client row was locked: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := holder.Rollback(); err != nil {
+		t.Fatalf("releasing lock: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("deposit after the lock was released: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deposit still blocked after the lock was released")
+	}
+
+	var balance int
+	if err := db.QueryRowContext(ctx, "SELECT balance FROM clients WHERE id=$1", account.ID).Scan(&balance); err != nil {
+		t.Fatalf("reading balance: %v", err)
+	}
+	if balance != 100 {
+		t.Fatalf("balance = %d, want 100", balance)
+	}
+}
+
+func TestScheduledTransactionRunsOnce(t *testing.T) {
+	db := testutil.Postgres(t)
+	repo := testutil.Repository(t, db, testutil.Config())
+
+	accounts := testutil.Seed(t, repo,
+		domain.CreateCostumer{Fullname: "Payor", Birth: "01/01/1990", Limit: 1000, Password: "pw"},
+		domain.CreateCostumer{Fullname: "Payee", Birth: "01/01/1990", Password: "pw"},
+	)
+	payor, payee := accounts[0], accounts[1]
+
+	ctx := context.Background()
+
+	scheduled, err := repo.CreateScheduledTransfer(ctx, domain.ScheduledTransfer{
+		Client_Id:     payor.ID,
+		Value:         100,
+		Description:   "rent",
+		PayeeUrubuKey: string(payee.UrubuKey),
+		NextRunAt:     time.Now().UTC().Add(-time.Minute),
+		Recurrence:    domain.RecurrenceOnce,
+	})
+	if err != nil {
+		t.Fatalf("scheduling: %v", err)
+	}
+
+	transaction := domain.TransactionDebit{
+		Client_Id:     payor.ID,
+		Value:         scheduled.Value,
+		Kind:          "debit",
+		Description:   scheduled.Description,
+		PayeeUrubuKey: scheduled.PayeeUrubuKey,
+		Completed_at:  time.Now(),
+	}
+
+	if _, err := repo.CreateScheduledTransaction(ctx, scheduled, transaction); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if _, err := repo.CreateScheduledTransaction(ctx, scheduled, transaction); !errors.Is(err, bank.ScheduledAlreadyRunErr) {
+		t.Fatalf("second run of the same due time: err = %v, want %v", err, bank.ScheduledAlreadyRunErr)
+	}
+
+	balance, err := bank.NewLedger().Balance(ctx, db, payor.ID)
+	if err != nil {
+		t.Fatalf("reading ledger: %v", err)
+	}
+	if balance != -100 {
+		t.Fatalf("payor balance = %d, want -100", balance)
+	}
+}
+
+func TestRunScheduledTransfersRecordsSuccess(t *testing.T) {
+	db := testutil.Postgres(t)
+	cfg := testutil.Config()
+	cfg.Timeouts.Transfer = 2 * time.Second
+	repo := testutil.Repository(t, db, cfg)
+	service := bank.NewService(repo, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	accounts := testutil.Seed(t, repo,
+		domain.CreateCostumer{Fullname: "Payor", Birth: "01/01/1990", Limit: 1000, Password: "pw"},
+		domain.CreateCostumer{Fullname: "Payee", Birth: "01/01/1990", Password: "pw"},
+	)
+	payor, payee := accounts[0], accounts[1]
+
+	ctx := context.Background()
+
+	scheduled, err := repo.CreateScheduledTransfer(ctx, domain.ScheduledTransfer{
+		Client_Id:     payor.ID,
+		Value:         100,
+		Description:   "rent",
+		PayeeUrubuKey: string(payee.UrubuKey),
+		NextRunAt:     time.Now().UTC().Add(-time.Minute),
+		Recurrence:    domain.RecurrenceOnce,
+	})
+	if err != nil {
+		t.Fatalf("scheduling: %v", err)
+	}
+
+	executed, err := service.RunScheduledTransfers(ctx)
+	if err != nil {
+		t.Fatalf("running scheduled transfers: %v", err)
+	}
+	if executed != 1 {
+		t.Fatalf("executed = %d, want 1", executed)
+	}
+
+	stored, err := repo.GetScheduledTransfer(ctx, payor.ID, scheduled.ID)
+	if err != nil {
+		t.Fatalf("reading scheduled transfer: %v", err)
+	}
+	if stored.Status != domain.ScheduledCompleted {
+		t.Fatalf("status = %s, want %s", stored.Status, domain.ScheduledCompleted)
+	}
+	if len(stored.Runs) != 1 || stored.Runs[0].Status != domain.RunSuccess {
+		t.Fatalf("runs = %+v, want one %s run", stored.Runs, domain.RunSuccess)
+	}
+
+	balance, err := bank.NewLedger().Balance(ctx, db, payor.ID)
+	if err != nil {
+		t.Fatalf("reading ledger: %v", err)
+	}
+	if balance != -100 {
+		t.Fatalf("payor balance = %d, want -100", balance)
+	}
+}
+
+func TestDocumentUrubuKeyMustBeOwnCPF(t *testing.T) {
+	repo := testutil.Repository(t, testutil.Postgres(t), testutil.Config())
+	ctx := context.Background()
+
+	owner, other := testutil.CPF(), testutil.CPF()
+	seeded := testutil.Seed(t, repo,
+		domain.CreateCostumer{Fullname: "Ana", Birth: "01/01/1990", Password: "pw", Document: owner},
+		domain.CreateCostumer{Fullname: "Bia", Birth: "02/02/1992", Password: "pw", Document: other},
+	)
+
+	if _, err := repo.RegisterUrubuKey(ctx, seeded[0].ID, domain.UrubuKeyDocument, domain.UrubuKey(other)); !errors.Is(err, bank.NotOwnDocumentErr) {
+		t.Fatalf("registering someone else's CPF: err = %v, want %v", err, bank.NotOwnDocumentErr)
+	}
+	if _, err := repo.RegisterUrubuKey(ctx, seeded[0].ID, domain.UrubuKeyDocument, domain.UrubuKey(owner)); err != nil {
+		t.Fatalf("registering own CPF: %v", err)
+	}
+}
+
+func TestUpdateCredentialsOfBackfilledAccount(t *testing.T) {
+	db := testutil.Postgres(t)
+	repo := testutil.Repository(t, db, testutil.Config())
+	ctx := context.Background()
+
+	account := testutil.Seed(t, repo, domain.CreateCostumer{Fullname: "Old", Birth: "01/01/1980", Password: "pw"})[0]
+
+	// Accounts from before credentials were backfilled with neither.
+	if _, err := db.ExecContext(ctx, "UPDATE credentials SET document=NULL, email=NULL WHERE client_id=$1", account.ID); err != nil {
+		t.Fatalf("clearing credentials: %v", err)
+	}
+
+	document := testutil.CPF()
+	updated, err := repo.UpdateCredentials(ctx, account.ID, domain.Credentials{Document: document, Email: "old@urubu.test"})
+	if err != nil {
+		t.Fatalf("setting credentials: %v", err)
+	}
+	if updated.Document != document || updated.Email != "old@urubu.test" {
+		t.Fatalf("updated = %+v", updated)
+	}
+
+	for _, login := range []domain.Login{{Kind: domain.LoginDocument, Value: document}, {Kind: domain.LoginEmail, Value: "old@urubu.test"}} {
+		user, err := repo.GetCredentials(ctx, login)
+		if err != nil {
+			t.Fatalf("logging in by %s: %v", login.Kind, err)
+		}
+		if user.ID != account.ID {
+			t.Fatalf("logging in by %s: id = %d, want %d", login.Kind, user.ID, account.ID)
+		}
+	}
+
+	if _, err := repo.UpdateCredentials(ctx, account.ID, domain.Credentials{Document: testutil.CPF()}); !errors.Is(err, bank.DocumentSetErr) {
+		t.Fatalf("replacing the document: err = %v, want %v", err, bank.DocumentSetErr)
+	}
+}
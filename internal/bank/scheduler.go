@@ -0,0 +1,45 @@
+package bank
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Scheduler struct {
+	service  Service
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewScheduler(s Service, interval time.Duration, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		service:  s,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run executes due transfers every interval until ctx is done. Once ctx is
+// cancelled only the transfer already in progress is allowed to finish; the
+// rest of the batch waits for the next start.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		executed, err := s.service.RunScheduledTransfers(ctx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "running scheduled transfers", "error", err)
+		}
+		if executed > 0 {
+			s.logger.InfoContext(ctx, "scheduled transfers executed", "count", executed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
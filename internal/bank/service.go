@@ -2,77 +2,138 @@ package bank
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"time"
 
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
 	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
 )
 
 type Service interface {
 	GenerateUrubukey(ctx context.Context, id int) (domain.UrubuKey, error)
+	RegisterUrubuKey(ctx context.Context, clientID int, kind, key string) (domain.RegisteredUrubuKey, error)
+	ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error)
+	DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error
+	LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, error)
+	AllowRequest(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error)
 	SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error)
-	GetBankStatement(ctx context.Context, id int) (domain.BankStatemant, error)
+	GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error)
+	StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error)
 	VerifyIfCostumerExists(ctx context.Context, id int) (string, error)
+	AuthorizeCostumer(ctx context.Context, sessionCostumerID, costumerID int) error
 	CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error)
-	GetUsernameAndPassword(ctx context.Context, name string) (domain.User, error)
-	CreateSessionToken(sessionName string) (string, error)
-	DeleteSessionToken(sessionName string) error
-	VerifyIfTokenExists(token string) error
-	RetrieveCookies(sessionName string) (string, error)
-	DeposityMoney(ctx context.Context, t domain.TransactionCredit, result chan domain.TransactionResponseCredit, errChan chan error)
-	CreateTransaction(ctx context.Context, t domain.TransactionDebit, result chan domain.TransactionResponseDebit, errChan chan error)
-	UrubuTrading(ctx context.Context, user domain.User, value int, result chan domain.ValueTraded, errChan chan error)
+	GetCredentials(ctx context.Context, login string) (domain.User, error)
+	UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error)
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (bool, error)
+	GetIdempotencyKey(ctx context.Context, key string) (domain.IdempotentResponse, error)
+	SaveIdempotencyKey(ctx context.Context, key string, response domain.IdempotentResponse) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
+	DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error)
+	CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error)
+	UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error)
+	ReverseTransaction(ctx context.Context, clientID, transactionID int) (domain.TransactionReversal, error)
+	CreateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error)
+	GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error)
+	UpdateScheduledTransfer(ctx context.Context, s domain.ScheduledTransfer) (domain.ScheduledTransfer, error)
+	CancelScheduledTransfer(ctx context.Context, clientID, id int) error
+	RunScheduledTransfers(ctx context.Context) (int, error)
 }
 
 type bankService struct {
 	repository Respository
+	logger     *slog.Logger
+	timeouts   config.TimeoutConfig
 }
 
-func NewService(r Respository) Service {
+func NewService(r Respository, cfg config.Config, logger *slog.Logger) Service {
 	return &bankService{
 		repository: r,
+		logger:     logger,
+		timeouts:   cfg.Timeouts,
 	}
 }
 
-func (s *bankService) UrubuTrading(ctx context.Context, user domain.User, value int, result chan domain.ValueTraded, errChan chan error) {
-	s.repository.UrubuTrading(ctx, user, value, result, errChan)
+func (s *bankService) UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Trading)
+	defer cancel()
+
+	earned, err := s.repository.UrubuTrading(ctx, user, value)
+
+	return earned, err
+}
+
+func (s *bankService) ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (bool, error) {
+	reserved, err := s.repository.ReserveIdempotencyKey(ctx, key, requestHash)
+
+	return reserved, err
 }
 
-func (s *bankService) RetrieveCookies(sessionName string) (string, error) {
-	token, err := s.repository.RetrieveCookies(sessionName)
+func (s *bankService) GetIdempotencyKey(ctx context.Context, key string) (domain.IdempotentResponse, error) {
+	response, err := s.repository.GetIdempotencyKey(ctx, key)
 
-	return token, err
+	return response, err
 }
 
-func (s *bankService) VerifyIfTokenExists(token string) error {
-	err := s.repository.VerifyIfTokenExists(token)
+func (s *bankService) SaveIdempotencyKey(ctx context.Context, key string, response domain.IdempotentResponse) error {
+	err := s.repository.SaveIdempotencyKey(ctx, key, response)
 
 	return err
 }
 
-func (s *bankService) DeleteSessionToken(sessionName string) error {
-	err := s.repository.DeleteSessionToken(sessionName)
+func (s *bankService) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	err := s.repository.DeleteIdempotencyKey(ctx, key)
 
 	return err
 }
 
-func (s *bankService) CreateSessionToken(sessionName string) (string, error) {
-	token, err := s.repository.CreateSessionToken(sessionName)
+func (s *bankService) GetCredentials(ctx context.Context, login string) (domain.User, error) {
+	parsed, err := ParseLogin(login)
+	if err != nil {
+		return domain.User{}, err
+	}
 
-	return token, err
+	response, err := s.repository.GetCredentials(ctx, parsed)
+	return response, err
 }
 
-func (s *bankService) GetUsernameAndPassword(ctx context.Context, name string) (domain.User, error) {
-	response, err := s.repository.GetUsernameAndPassword(ctx, name)
+func (s *bankService) UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error) {
+	credentials, err := normalizeCredentialsUpdate(credentials)
+	if err != nil {
+		return domain.Credentials{}, err
+	}
+
+	updated, err := s.repository.UpdateCredentials(ctx, clientID, credentials)
+
+	return updated, err
+}
+
+func (s *bankService) DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Deposit)
+	defer cancel()
+
+	response, err := s.repository.DeposityMoney(ctx, t)
+
 	return response, err
 }
 
-func (s *bankService) DeposityMoney(ctx context.Context, t domain.TransactionCredit, result chan domain.TransactionResponseCredit, errChan chan error) {
-	s.repository.DeposityMoney(ctx, t, result, errChan)
+func (s *bankService) CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Transfer)
+	defer cancel()
 
-	return
+	response, err := s.repository.CreateTransaction(ctx, t)
+
+	return response, err
 }
 
-func (s *bankService) CreateTransaction(ctx context.Context, t domain.TransactionDebit, result chan domain.TransactionResponseDebit, errChan chan error) {
-	s.repository.CreateTransaction(ctx, t, result, errChan)
+func (s *bankService) ReverseTransaction(ctx context.Context, clientID, transactionID int) (domain.TransactionReversal, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Reversal)
+	defer cancel()
+
+	reversal, err := s.repository.ReverseTransaction(ctx, clientID, transactionID, time.Now())
+
+	return reversal, err
 }
 
 func (s *bankService) SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error) {
@@ -81,19 +142,91 @@ func (s *bankService) SearchClientByName(ctx context.Context, name string) ([]do
 	return clients, err
 }
 
-func (s *bankService) GetBankStatement(ctx context.Context, id int) (domain.BankStatemant, error) {
-	bankStatemant, err := s.repository.GetBankStatement(ctx, id)
+func (s *bankService) GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultStatementLimit
+	}
+	if filter.Limit > MaxStatementLimit {
+		filter.Limit = MaxStatementLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Statement)
+	defer cancel()
+
+	bankStatemant, err := s.repository.GetBankStatement(ctx, id, filter)
 
 	return bankStatemant, err
 }
 
+func (s *bankService) StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.StatementExport)
+
+	stream, err := s.repository.StreamBankStatement(ctx, id, filter)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &cancelStream{StatementStream: stream, cancel: cancel}, nil
+}
+
 func (s *bankService) GenerateUrubukey(ctx context.Context, id int) (domain.UrubuKey, error) {
 	urubukey, err := s.repository.GenerateUrubuKey(ctx, id)
 
 	return urubukey, err
 }
 
+func (s *bankService) RegisterUrubuKey(ctx context.Context, clientID int, kind, key string) (domain.RegisteredUrubuKey, error) {
+	urubukey, err := NormalizeUrubuKey(kind, key)
+	if err != nil {
+		return domain.RegisteredUrubuKey{}, err
+	}
+
+	registered, err := s.repository.RegisterUrubuKey(ctx, clientID, kind, urubukey)
+
+	return registered, err
+}
+
+func (s *bankService) ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error) {
+	keys, err := s.repository.ListUrubuKeys(ctx, clientID)
+
+	return keys, err
+}
+
+func (s *bankService) DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error {
+	err := s.repository.DeleteUrubuKey(ctx, clientID, key)
+
+	return err
+}
+
+func (s *bankService) LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, error) {
+	owner, clientID, err := s.repository.LookupUrubuKey(ctx, key)
+	if err != nil {
+		return domain.UrubuKeyOwner{}, err
+	}
+
+	owner.Name = maskName(owner.Name)
+	owner.Bank = bankName
+	owner.Account = maskAccount(clientID)
+
+	return owner, nil
+}
+
+func (s *bankService) AllowRequest(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	count, retryAfter, err := s.repository.IncrementRateLimit(ctx, key, window)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return count <= int64(limit), retryAfter, nil
+}
+
 func (s *bankService) CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error) {
+	client, err := normalizeCredentials(client)
+	if err != nil {
+		return domain.CreatedCostumer{}, err
+	}
+
 	response, err := s.repository.CreateNewAccount(ctx, client)
 
 	return response, err
@@ -104,3 +237,105 @@ func (s *bankService) VerifyIfCostumerExists(ctx context.Context, id int) (strin
 
 	return clientname, err
 }
+
+func (s *bankService) AuthorizeCostumer(ctx context.Context, sessionCostumerID, costumerID int) error {
+	if sessionCostumerID == costumerID {
+		return nil
+	}
+
+	isAdmin, err := s.repository.IsAdmin(ctx, sessionCostumerID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return ForbiddenErr
+	}
+
+	return nil
+}
+
+func (s *bankService) CreateScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	created, err := s.repository.CreateScheduledTransfer(ctx, scheduled)
+
+	return created, err
+}
+
+func (s *bankService) ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error) {
+	scheduled, err := s.repository.ListScheduledTransfers(ctx, clientID)
+
+	return scheduled, err
+}
+
+func (s *bankService) GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error) {
+	scheduled, err := s.repository.GetScheduledTransfer(ctx, clientID, id)
+
+	return scheduled, err
+}
+
+func (s *bankService) UpdateScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	updated, err := s.repository.UpdateScheduledTransfer(ctx, scheduled)
+
+	return updated, err
+}
+
+func (s *bankService) CancelScheduledTransfer(ctx context.Context, clientID, id int) error {
+	err := s.repository.CancelScheduledTransfer(ctx, clientID, id)
+
+	return err
+}
+
+// RunScheduledTransfers executes due transfers one at a time until none is
+// left or ctx is done. Cancelling ctx never interrupts a transfer halfway:
+// the one in progress finishes and no other is started.
+func (s *bankService) RunScheduledTransfers(ctx context.Context) (int, error) {
+	executed := 0
+	work := context.WithoutCancel(ctx)
+
+	for ctx.Err() == nil {
+		processed, err := s.repository.ProcessDueScheduledTransfer(work, time.Now().UTC(), func(scheduled domain.ScheduledTransfer) error {
+			err := s.executeScheduledTransfer(work, scheduled)
+			if errors.Is(err, ScheduledAlreadyRunErr) {
+				s.logger.InfoContext(work, "scheduled transfer already ran, recording it", "scheduled_id", scheduled.ID, "client_id", scheduled.Client_Id)
+				return nil
+			}
+			if err != nil {
+				s.logger.WarnContext(work, "scheduled transfer failed", "scheduled_id", scheduled.ID, "client_id", scheduled.Client_Id, "error", err)
+			}
+			return err
+		})
+		if err != nil {
+			return executed, err
+		}
+		if !processed {
+			return executed, nil
+		}
+
+		executed++
+	}
+
+	return executed, nil
+}
+
+func (s *bankService) executeScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) error {
+	payor, err := s.repository.VerifyIfClientExists(ctx, scheduled.Client_Id)
+	if err != nil {
+		return err
+	}
+
+	transaction := domain.TransactionDebit{
+		Client_Id:     scheduled.Client_Id,
+		Value:         scheduled.Value,
+		Kind:          "debit",
+		Description:   scheduled.Description,
+		Payor:         payor,
+		PayeeUrubuKey: scheduled.PayeeUrubuKey,
+		Completed_at:  time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Transfer)
+	defer cancel()
+
+	_, err = s.repository.CreateScheduledTransaction(ctx, scheduled, transaction)
+
+	return err
+}
@@ -0,0 +1,104 @@
+package bank
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+)
+
+const (
+	DefaultStatementLimit = 10
+	MaxStatementLimit     = 100
+)
+
+var InvalidCursorErr = apperr.New("invalid_cursor", http.StatusBadRequest, "invalid cursor")
+
+func EncodeStatementCursor(cursor domain.StatementCursor) string {
+	raw := cursor.Completed_at.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeStatementCursor(encoded string) (domain.StatementCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return domain.StatementCursor{}, InvalidCursorErr
+	}
+
+	completedAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return domain.StatementCursor{}, InvalidCursorErr
+	}
+
+	cursor := domain.StatementCursor{}
+
+	cursor.Completed_at, err = time.Parse(time.RFC3339Nano, completedAt)
+	if err != nil {
+		return domain.StatementCursor{}, InvalidCursorErr
+	}
+
+	cursor.ID, err = strconv.Atoi(id)
+	if err != nil {
+		return domain.StatementCursor{}, InvalidCursorErr
+	}
+
+	return cursor, nil
+}
+
+type StatementStream interface {
+	Balance() domain.BalanceStatement
+	Next() bool
+	Transaction() (domain.LastTransaction, error)
+	Err() error
+	Close() error
+}
+
+// cancelStream ends the stream's deadline when it is closed. Past the
+// deadline the transaction is rolled back and its connection goes back to
+// the pool, however slowly the client is reading.
+type cancelStream struct {
+	StatementStream
+	cancel context.CancelFunc
+}
+
+func (s *cancelStream) Close() error {
+	defer s.cancel()
+	return s.StatementStream.Close()
+}
+
+type sqlStatementStream struct {
+	balance domain.BalanceStatement
+	tx      *sql.Tx
+	rows    *sql.Rows
+}
+
+func (s *sqlStatementStream) Balance() domain.BalanceStatement {
+	return s.balance
+}
+
+func (s *sqlStatementStream) Next() bool {
+	return s.rows.Next()
+}
+
+func (s *sqlStatementStream) Transaction() (domain.LastTransaction, error) {
+	return scanStatementRow(s.rows)
+}
+
+func (s *sqlStatementStream) Err() error {
+	return s.rows.Err()
+}
+
+func (s *sqlStatementStream) Close() error {
+	if err := s.rows.Close(); err != nil {
+		_ = s.tx.Rollback()
+		return err
+	}
+
+	return s.tx.Rollback()
+}
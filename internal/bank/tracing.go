@@ -0,0 +1,404 @@
+package bank
+
+import (
+	"context"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/FelipeMCassiano/urubu_bank/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func clientAttr(id int) attribute.KeyValue {
+	return attribute.Int("client.id", id)
+}
+
+var (
+	postgresAttr = semconv.DBSystemPostgreSQL
+	redisAttr    = semconv.DBSystemRedis
+)
+
+type tracedService struct {
+	Service
+}
+
+// NewTracedService wraps s so its business operations run in their own spans.
+func NewTracedService(s Service) Service {
+	return tracedService{s}
+}
+
+func (s tracedService) GenerateUrubukey(ctx context.Context, id int) (domain.UrubuKey, error) {
+	ctx, span := tracing.Start(ctx, "bankService.GenerateUrubukey", clientAttr(id))
+	key, err := s.Service.GenerateUrubukey(ctx, id)
+	tracing.End(span, err)
+	return key, err
+}
+
+func (s tracedService) RegisterUrubuKey(ctx context.Context, clientID int, kind, key string) (domain.RegisteredUrubuKey, error) {
+	ctx, span := tracing.Start(ctx, "bankService.RegisterUrubuKey", clientAttr(clientID), attribute.String("urubukey.kind", kind))
+	registered, err := s.Service.RegisterUrubuKey(ctx, clientID, kind, key)
+	tracing.End(span, err)
+	return registered, err
+}
+
+func (s tracedService) ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error) {
+	ctx, span := tracing.Start(ctx, "bankService.ListUrubuKeys", clientAttr(clientID))
+	keys, err := s.Service.ListUrubuKeys(ctx, clientID)
+	tracing.End(span, err)
+	return keys, err
+}
+
+func (s tracedService) DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error {
+	ctx, span := tracing.Start(ctx, "bankService.DeleteUrubuKey", clientAttr(clientID))
+	err := s.Service.DeleteUrubuKey(ctx, clientID, key)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedService) LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, error) {
+	ctx, span := tracing.Start(ctx, "bankService.LookupUrubuKey")
+	owner, err := s.Service.LookupUrubuKey(ctx, key)
+	tracing.End(span, err)
+	return owner, err
+}
+
+func (s tracedService) SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error) {
+	ctx, span := tracing.Start(ctx, "bankService.SearchClientByName")
+	costumers, err := s.Service.SearchClientByName(ctx, name)
+	tracing.End(span, err)
+	return costumers, err
+}
+
+func (s tracedService) GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error) {
+	ctx, span := tracing.Start(ctx, "bankService.GetBankStatement", clientAttr(id))
+	statement, err := s.Service.GetBankStatement(ctx, id, filter)
+	tracing.End(span, err)
+	return statement, err
+}
+
+func (s tracedService) StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error) {
+	ctx, span := tracing.Start(ctx, "bankService.StreamBankStatement", clientAttr(id))
+	stream, err := s.Service.StreamBankStatement(ctx, id, filter)
+	tracing.End(span, err)
+	return stream, err
+}
+
+func (s tracedService) VerifyIfCostumerExists(ctx context.Context, id int) (string, error) {
+	ctx, span := tracing.Start(ctx, "bankService.VerifyIfCostumerExists", clientAttr(id))
+	name, err := s.Service.VerifyIfCostumerExists(ctx, id)
+	tracing.End(span, err)
+	return name, err
+}
+
+func (s tracedService) AuthorizeCostumer(ctx context.Context, sessionCostumerID, costumerID int) error {
+	ctx, span := tracing.Start(ctx, "bankService.AuthorizeCostumer", clientAttr(costumerID))
+	err := s.Service.AuthorizeCostumer(ctx, sessionCostumerID, costumerID)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedService) CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error) {
+	ctx, span := tracing.Start(ctx, "bankService.CreateNewAccount")
+	created, err := s.Service.CreateNewAccount(ctx, client)
+	tracing.End(span, err)
+	return created, err
+}
+
+func (s tracedService) UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error) {
+	ctx, span := tracing.Start(ctx, "bankService.UpdateCredentials", clientAttr(clientID))
+	updated, err := s.Service.UpdateCredentials(ctx, clientID, credentials)
+	tracing.End(span, err)
+	return updated, err
+}
+
+func (s tracedService) GetCredentials(ctx context.Context, login string) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "bankService.GetCredentials")
+	user, err := s.Service.GetCredentials(ctx, login)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (s tracedService) DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error) {
+	ctx, span := tracing.Start(ctx, "bankService.DeposityMoney", clientAttr(t.Client_Id), attribute.Int("transaction.value", t.Value))
+	response, err := s.Service.DeposityMoney(ctx, t)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (s tracedService) CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	ctx, span := tracing.Start(ctx, "bankService.CreateTransaction", clientAttr(t.Client_Id), attribute.Int("transaction.value", t.Value))
+	response, err := s.Service.CreateTransaction(ctx, t)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (s tracedService) UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error) {
+	ctx, span := tracing.Start(ctx, "bankService.UrubuTrading", clientAttr(user.ID), attribute.Int("transaction.value", value))
+	earned, err := s.Service.UrubuTrading(ctx, user, value)
+	tracing.End(span, err)
+	return earned, err
+}
+
+func (s tracedService) ReverseTransaction(ctx context.Context, clientID, transactionID int) (domain.TransactionReversal, error) {
+	ctx, span := tracing.Start(ctx, "bankService.ReverseTransaction", clientAttr(clientID), attribute.Int("transaction.id", transactionID))
+	reversal, err := s.Service.ReverseTransaction(ctx, clientID, transactionID)
+	tracing.End(span, err)
+	return reversal, err
+}
+
+func (s tracedService) CreateScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "bankService.CreateScheduledTransfer", clientAttr(scheduled.Client_Id))
+	created, err := s.Service.CreateScheduledTransfer(ctx, scheduled)
+	tracing.End(span, err)
+	return created, err
+}
+
+func (s tracedService) ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "bankService.ListScheduledTransfers", clientAttr(clientID))
+	scheduled, err := s.Service.ListScheduledTransfers(ctx, clientID)
+	tracing.End(span, err)
+	return scheduled, err
+}
+
+func (s tracedService) GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "bankService.GetScheduledTransfer", clientAttr(clientID))
+	scheduled, err := s.Service.GetScheduledTransfer(ctx, clientID, id)
+	tracing.End(span, err)
+	return scheduled, err
+}
+
+func (s tracedService) UpdateScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "bankService.UpdateScheduledTransfer", clientAttr(scheduled.Client_Id))
+	updated, err := s.Service.UpdateScheduledTransfer(ctx, scheduled)
+	tracing.End(span, err)
+	return updated, err
+}
+
+func (s tracedService) CancelScheduledTransfer(ctx context.Context, clientID, id int) error {
+	ctx, span := tracing.Start(ctx, "bankService.CancelScheduledTransfer", clientAttr(clientID))
+	err := s.Service.CancelScheduledTransfer(ctx, clientID, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedService) RunScheduledTransfers(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "bankService.RunScheduledTransfers")
+	executed, err := s.Service.RunScheduledTransfers(ctx)
+	span.SetAttributes(attribute.Int("scheduled.executed", executed))
+	tracing.End(span, err)
+	return executed, err
+}
+
+type tracedRepository struct {
+	Respository
+}
+
+// NewTracedRepository wraps r so every query runs in its own span tagged with
+// the backing store.
+func NewTracedRepository(r Respository) Respository {
+	return tracedRepository{r}
+}
+
+func (r tracedRepository) GetBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (domain.BankStatemant, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetBankStatement", postgresAttr, clientAttr(id))
+	statement, err := r.Respository.GetBankStatement(ctx, id, filter)
+	tracing.End(span, err)
+	return statement, err
+}
+
+func (r tracedRepository) StreamBankStatement(ctx context.Context, id int, filter domain.StatementFilter) (StatementStream, error) {
+	ctx, span := tracing.Start(ctx, "repository.StreamBankStatement", postgresAttr, clientAttr(id))
+	stream, err := r.Respository.StreamBankStatement(ctx, id, filter)
+	tracing.End(span, err)
+	return stream, err
+}
+
+func (r tracedRepository) GenerateUrubuKey(ctx context.Context, id int) (domain.UrubuKey, error) {
+	ctx, span := tracing.Start(ctx, "repository.GenerateUrubuKey", postgresAttr, clientAttr(id))
+	key, err := r.Respository.GenerateUrubuKey(ctx, id)
+	tracing.End(span, err)
+	return key, err
+}
+
+func (r tracedRepository) RegisterUrubuKey(ctx context.Context, clientID int, kind string, key domain.UrubuKey) (domain.RegisteredUrubuKey, error) {
+	ctx, span := tracing.Start(ctx, "repository.RegisterUrubuKey", postgresAttr, clientAttr(clientID))
+	registered, err := r.Respository.RegisterUrubuKey(ctx, clientID, kind, key)
+	tracing.End(span, err)
+	return registered, err
+}
+
+func (r tracedRepository) ListUrubuKeys(ctx context.Context, clientID int) ([]domain.RegisteredUrubuKey, error) {
+	ctx, span := tracing.Start(ctx, "repository.ListUrubuKeys", postgresAttr, clientAttr(clientID))
+	keys, err := r.Respository.ListUrubuKeys(ctx, clientID)
+	tracing.End(span, err)
+	return keys, err
+}
+
+func (r tracedRepository) DeleteUrubuKey(ctx context.Context, clientID int, key domain.UrubuKey) error {
+	ctx, span := tracing.Start(ctx, "repository.DeleteUrubuKey", postgresAttr, clientAttr(clientID))
+	err := r.Respository.DeleteUrubuKey(ctx, clientID, key)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRepository) LookupUrubuKey(ctx context.Context, key string) (domain.UrubuKeyOwner, int, error) {
+	ctx, span := tracing.Start(ctx, "repository.LookupUrubuKey", postgresAttr)
+	owner, clientID, err := r.Respository.LookupUrubuKey(ctx, key)
+	tracing.End(span, err)
+	return owner, clientID, err
+}
+
+func (r tracedRepository) SearchClientByName(ctx context.Context, name string) ([]domain.CostumerConsult, error) {
+	ctx, span := tracing.Start(ctx, "repository.SearchClientByName", postgresAttr)
+	costumers, err := r.Respository.SearchClientByName(ctx, name)
+	tracing.End(span, err)
+	return costumers, err
+}
+
+func (r tracedRepository) CreateNewAccount(ctx context.Context, client domain.CreateCostumer) (domain.CreatedCostumer, error) {
+	ctx, span := tracing.Start(ctx, "repository.CreateNewAccount", postgresAttr)
+	created, err := r.Respository.CreateNewAccount(ctx, client)
+	tracing.End(span, err)
+	return created, err
+}
+
+func (r tracedRepository) VerifyIfClientExists(ctx context.Context, id int) (string, error) {
+	ctx, span := tracing.Start(ctx, "repository.VerifyIfClientExists", postgresAttr, clientAttr(id))
+	name, err := r.Respository.VerifyIfClientExists(ctx, id)
+	tracing.End(span, err)
+	return name, err
+}
+
+func (r tracedRepository) IsAdmin(ctx context.Context, id int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "repository.IsAdmin", postgresAttr, clientAttr(id))
+	admin, err := r.Respository.IsAdmin(ctx, id)
+	tracing.End(span, err)
+	return admin, err
+}
+
+func (r tracedRepository) GetCredentials(ctx context.Context, login domain.Login) (domain.User, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetCredentials", postgresAttr, attribute.String("login.kind", login.Kind))
+	user, err := r.Respository.GetCredentials(ctx, login)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (r tracedRepository) UpdateCredentials(ctx context.Context, clientID int, credentials domain.Credentials) (domain.Credentials, error) {
+	ctx, span := tracing.Start(ctx, "repository.UpdateCredentials", postgresAttr, clientAttr(clientID))
+	updated, err := r.Respository.UpdateCredentials(ctx, clientID, credentials)
+	tracing.End(span, err)
+	return updated, err
+}
+
+func (r tracedRepository) DeposityMoney(ctx context.Context, t domain.TransactionCredit) (domain.TransactionResponseCredit, error) {
+	ctx, span := tracing.Start(ctx, "repository.DeposityMoney", postgresAttr, clientAttr(t.Client_Id))
+	response, err := r.Respository.DeposityMoney(ctx, t)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (r tracedRepository) CreateTransaction(ctx context.Context, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	ctx, span := tracing.Start(ctx, "repository.CreateTransaction", postgresAttr, clientAttr(t.Client_Id))
+	response, err := r.Respository.CreateTransaction(ctx, t)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (r tracedRepository) CreateScheduledTransaction(ctx context.Context, s domain.ScheduledTransfer, t domain.TransactionDebit) (domain.TransactionResponseDebit, error) {
+	ctx, span := tracing.Start(ctx, "repository.CreateScheduledTransaction", postgresAttr, clientAttr(t.Client_Id), attribute.Int("scheduled.id", s.ID))
+	response, err := r.Respository.CreateScheduledTransaction(ctx, s, t)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (r tracedRepository) UrubuTrading(ctx context.Context, user domain.User, value int) (domain.ValueTraded, error) {
+	ctx, span := tracing.Start(ctx, "repository.UrubuTrading", postgresAttr, clientAttr(user.ID))
+	earned, err := r.Respository.UrubuTrading(ctx, user, value)
+	tracing.End(span, err)
+	return earned, err
+}
+
+func (r tracedRepository) ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "repository.ReserveIdempotencyKey", redisAttr)
+	reserved, err := r.Respository.ReserveIdempotencyKey(ctx, key, requestHash)
+	tracing.End(span, err)
+	return reserved, err
+}
+
+func (r tracedRepository) GetIdempotencyKey(ctx context.Context, key string) (domain.IdempotentResponse, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetIdempotencyKey", redisAttr)
+	response, err := r.Respository.GetIdempotencyKey(ctx, key)
+	tracing.End(span, err)
+	return response, err
+}
+
+func (r tracedRepository) SaveIdempotencyKey(ctx context.Context, key string, response domain.IdempotentResponse) error {
+	ctx, span := tracing.Start(ctx, "repository.SaveIdempotencyKey", redisAttr)
+	err := r.Respository.SaveIdempotencyKey(ctx, key, response)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRepository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	ctx, span := tracing.Start(ctx, "repository.DeleteIdempotencyKey", redisAttr)
+	err := r.Respository.DeleteIdempotencyKey(ctx, key)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRepository) IncrementRateLimit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	ctx, span := tracing.Start(ctx, "repository.IncrementRateLimit", redisAttr)
+	count, retryAfter, err := r.Respository.IncrementRateLimit(ctx, key, window)
+	tracing.End(span, err)
+	return count, retryAfter, err
+}
+
+func (r tracedRepository) ReverseTransaction(ctx context.Context, clientID, transactionID int, completedAt time.Time) (domain.TransactionReversal, error) {
+	ctx, span := tracing.Start(ctx, "repository.ReverseTransaction", postgresAttr, clientAttr(clientID), attribute.Int("transaction.id", transactionID))
+	reversal, err := r.Respository.ReverseTransaction(ctx, clientID, transactionID, completedAt)
+	tracing.End(span, err)
+	return reversal, err
+}
+
+func (r tracedRepository) CreateScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "repository.CreateScheduledTransfer", postgresAttr, clientAttr(scheduled.Client_Id))
+	created, err := r.Respository.CreateScheduledTransfer(ctx, scheduled)
+	tracing.End(span, err)
+	return created, err
+}
+
+func (r tracedRepository) ListScheduledTransfers(ctx context.Context, clientID int) ([]domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "repository.ListScheduledTransfers", postgresAttr, clientAttr(clientID))
+	scheduled, err := r.Respository.ListScheduledTransfers(ctx, clientID)
+	tracing.End(span, err)
+	return scheduled, err
+}
+
+func (r tracedRepository) GetScheduledTransfer(ctx context.Context, clientID, id int) (domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetScheduledTransfer", postgresAttr, clientAttr(clientID))
+	scheduled, err := r.Respository.GetScheduledTransfer(ctx, clientID, id)
+	tracing.End(span, err)
+	return scheduled, err
+}
+
+func (r tracedRepository) UpdateScheduledTransfer(ctx context.Context, scheduled domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	ctx, span := tracing.Start(ctx, "repository.UpdateScheduledTransfer", postgresAttr, clientAttr(scheduled.Client_Id))
+	updated, err := r.Respository.UpdateScheduledTransfer(ctx, scheduled)
+	tracing.End(span, err)
+	return updated, err
+}
+
+func (r tracedRepository) CancelScheduledTransfer(ctx context.Context, clientID, id int) error {
+	ctx, span := tracing.Start(ctx, "repository.CancelScheduledTransfer", postgresAttr, clientAttr(clientID))
+	err := r.Respository.CancelScheduledTransfer(ctx, clientID, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRepository) ProcessDueScheduledTransfer(ctx context.Context, now time.Time, execute func(domain.ScheduledTransfer) error) (bool, error) {
+	ctx, span := tracing.Start(ctx, "repository.ProcessDueScheduledTransfer", postgresAttr)
+	processed, err := r.Respository.ProcessDueScheduledTransfer(ctx, now, execute)
+	tracing.End(span, err)
+	return processed, err
+}
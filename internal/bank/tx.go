@@ -0,0 +1,102 @@
+package bank
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+type lockedClient struct {
+	ID       int
+	Fullname string
+	Limit    int
+	Balance  int
+}
+
+// inTx runs fn in a serializable transaction, retrying it from scratch when
+// Postgres aborts it with a serialization failure or a deadlock. Once ctx is
+// done its error is returned instead of whatever the driver reported.
+func (r *repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	var err error
+
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = r.runTx(ctx, fn)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		r.logger.DebugContext(ctx, "retrying transaction", "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+
+	return err
+}
+
+func (r *repository) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// lockClients takes row locks on every client in ascending id order so two
+// transfers between the same accounts can never wait on each other in a cycle.
+func lockClients(ctx context.Context, tx *sql.Tx, ids ...int) (map[int]lockedClient, error) {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	clients := make(map[int]lockedClient, len(sorted))
+
+	for _, id := range sorted {
+		if _, ok := clients[id]; ok {
+			continue
+		}
+
+		var client lockedClient
+		err := tx.QueryRowContext(ctx, "SELECT id, fullname, credit_limit, balance FROM clients WHERE id=$1 FOR UPDATE", id).
+			Scan(&client.ID, &client.Fullname, &client.Limit, &client.Balance)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+
+		clients[id] = client
+	}
+
+	return clients, nil
+}
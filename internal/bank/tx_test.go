@@ -0,0 +1,278 @@
+package bank_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/FelipeMCassiano/urubu_bank/internal/testutil"
+	"github.com/lib/pq"
+)
+
+const (
+	serializationFailure = pq.ErrorCode("40001")
+	deadlockDetected     = pq.ErrorCode("40P01")
+)
+
+// retryRecorder counts, by Postgres error code, the transactions inTx had to
+// retry. Retries hide deadlocks from callers, so tests look here instead.
+type retryRecorder struct {
+	mu    sync.Mutex
+	codes map[pq.ErrorCode]int
+}
+
+func (r *retryRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (r *retryRecorder) Handle(_ context.Context, record slog.Record) error {
+	if record.Message != "retrying transaction" {
+		return nil
+	}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		err, ok := attr.Value.Any().(error)
+		var pqErr *pq.Error
+		if ok && errors.As(err, &pqErr) {
+			r.mu.Lock()
+			r.codes[pqErr.Code]++
+			r.mu.Unlock()
+		}
+		return true
+	})
+
+	return nil
+}
+
+func (r *retryRecorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+func (r *retryRecorder) WithGroup(string) slog.Handler      { return r }
+
+func (r *retryRecorder) count(code pq.ErrorCode) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.codes[code]
+}
+
+func newRecordedRepository(t *testing.T) (*sql.DB, bank.Respository, *retryRecorder) {
+	t.Helper()
+
+	db := testutil.Postgres(t)
+	recorder := &retryRecorder{codes: map[pq.ErrorCode]int{}}
+	repo := bank.NewRepository(db, cache.NewMemory(), testutil.Config(), slog.New(recorder))
+
+	return db, repo, recorder
+}
+
+// gaveUp reports whether err is a serialization failure that outlived every
+// retry. Under heavy contention that is an expected refusal, not a bug: the
+// move did not happen and the invariants below still have to hold.
+func gaveUp(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == serializationFailure
+}
+
+func assertBalance(t *testing.T, db *sql.DB, id, want int) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var cached int
+	if err := db.QueryRowContext(ctx, "SELECT balance FROM clients WHERE id=$1", id).Scan(&cached); err != nil {
+		t.Fatalf("reading balance of %d: %v", id, err)
+	}
+
+	ledger, err := bank.NewLedger().Balance(ctx, db, id)
+	if err != nil {
+		t.Fatalf("reading ledger of %d: %v", id, err)
+	}
+
+	if cached != want || ledger != want {
+		t.Errorf("client %d: clients.balance = %d, ledger = %d, want %d", id, cached, ledger, want)
+	}
+}
+
+func TestConcurrentMovesKeepBalanceInvariant(t *testing.T) {
+	db, repo, _ := newRecordedRepository(t)
+
+	const (
+		limit    = 500
+		workers  = 16
+		moves    = 8
+		deposit  = 50
+		transfer = 100
+	)
+
+	accounts := testutil.Seed(t, repo,
+		domain.CreateCostumer{Fullname: "Payor", Birth: "01/01/1990", Limit: limit, Password: "pw"},
+		domain.CreateCostumer{Fullname: "Payee", Birth: "01/01/1990", Password: "pw"},
+	)
+	payor, payee := accounts[0], accounts[1]
+
+	ctx := context.Background()
+
+	var deposited, transferred, succeeded atomic.Int64
+
+	stop := make(chan struct{})
+	lowest := make(chan int, 1)
+	go func() {
+		seen := 0
+		defer func() { lowest <- seen }()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+
+			var balance int
+			if err := db.QueryRowContext(ctx, "SELECT balance FROM clients WHERE id=$1", payor.ID).Scan(&balance); err != nil {
+				t.Errorf("sampling balance: %v", err)
+				return
+			}
+			if balance < seen {
+				seen = balance
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < moves; i++ {
+				if (w+i)%4 == 0 {
+					_, err := repo.DeposityMoney(ctx, domain.TransactionCredit{
+						Client_Id:    payor.ID,
+						Value:        deposit,
+						Kind:         "credit",
+						Description:  "hammer",
+						Completed_at: time.Now(),
+					})
+					switch {
+					case err == nil:
+						deposited.Add(deposit)
+						succeeded.Add(1)
+					case !gaveUp(err):
+						t.Errorf("deposit: %v", err)
+					}
+					continue
+				}
+
+				response, err := repo.CreateTransaction(ctx, domain.TransactionDebit{
+					Client_Id:     payor.ID,
+					Value:         transfer,
+					Kind:          "debit",
+					Description:   "hammer",
+					PayeeUrubuKey: string(payee.UrubuKey),
+					Completed_at:  time.Now(),
+				})
+				switch {
+				case err == nil:
+					transferred.Add(transfer)
+					succeeded.Add(1)
+					if response.Balance < -limit {
+						t.Errorf("transfer left balance %d below -%d", response.Balance, limit)
+					}
+				case errors.Is(err, bank.LimitErr), gaveUp(err):
+				default:
+					t.Errorf("transfer: %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	close(stop)
+	if seen := <-lowest; seen < -limit {
+		t.Errorf("balance reached %d, below -%d", seen, limit)
+	}
+
+	if succeeded.Load() == 0 {
+		t.Fatal("no move succeeded")
+	}
+
+	assertBalance(t, db, payor.ID, int(deposited.Load()-transferred.Load()))
+	assertBalance(t, db, payee.ID, int(transferred.Load()))
+}
+
+func TestOpposingTransfersDoNotDeadlock(t *testing.T) {
+	db, repo, recorder := newRecordedRepository(t)
+
+	const (
+		initial = 10000
+		workers = 16
+		moves   = 10
+		value   = 10
+	)
+
+	accounts := testutil.Seed(t, repo,
+		domain.CreateCostumer{Fullname: "A", Birth: "01/01/1990", Password: "pw"},
+		domain.CreateCostumer{Fullname: "B", Birth: "01/01/1990", Password: "pw"},
+	)
+	a, b := accounts[0], accounts[1]
+
+	ctx := context.Background()
+
+	for _, account := range accounts {
+		_, err := repo.DeposityMoney(ctx, domain.TransactionCredit{
+			Client_Id:    account.ID,
+			Value:        initial,
+			Kind:         "credit",
+			Description:  "initial",
+			Completed_at: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("funding %d: %v", account.ID, err)
+		}
+	}
+
+	var aToB, bToA atomic.Int64
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			payor, payee, moved := a, b, &aToB
+			if w%2 == 1 {
+				payor, payee, moved = b, a, &bToA
+			}
+
+			for i := 0; i < moves; i++ {
+				_, err := repo.CreateTransaction(ctx, domain.TransactionDebit{
+					Client_Id:     payor.ID,
+					Value:         value,
+					Kind:          "debit",
+					Description:   "opposing",
+					PayeeUrubuKey: string(payee.UrubuKey),
+					Completed_at:  time.Now(),
+				})
+				switch {
+				case err == nil:
+					moved.Add(value)
+				case !gaveUp(err):
+					t.Errorf("transfer %d -> %d: %v", payor.ID, payee.ID, err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if deadlocks := recorder.count(deadlockDetected); deadlocks > 0 {
+		t.Errorf("postgres detected %d deadlocks", deadlocks)
+	}
+
+	assertBalance(t, db, a.ID, initial-int(aToB.Load())+int(bToA.Load()))
+	assertBalance(t, db, b.ID, initial-int(bToA.Load())+int(aToB.Load()))
+}
@@ -0,0 +1,142 @@
+package bank
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	MaxUrubuKeysPerClient = 5
+
+	bankName = "urubu_bank"
+)
+
+var (
+	InvalidUrubuKeyErr  = apperr.New("invalid_urubukey", http.StatusUnprocessableEntity, "invalid urubukey for its kind")
+	UrubuKeyTakenErr    = apperr.New("urubukey_taken", http.StatusConflict, "urubukey already registered")
+	UrubuKeyLimitErr    = apperr.New("urubukey_limit_reached", http.StatusUnprocessableEntity, "urubukey limit reached")
+	UrubuKeyNotFoundErr = apperr.New("urubukey_not_found", http.StatusNotFound, "urubukey not found")
+	NotOwnDocumentErr   = apperr.New("urubukey_not_own_document", http.StatusUnprocessableEntity, "document urubukey must be the account holder's CPF")
+)
+
+var (
+	phonePattern    = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+	documentPattern = regexp.MustCompile(`^[0-9]{11}$`)
+	documentMarks   = strings.NewReplacer(".", "", "-", "")
+)
+
+// NormalizeUrubuKey validates key against the format of its kind and returns
+// the canonical form stored in urubu_keys. Random keys are generated here.
+func NormalizeUrubuKey(kind, key string) (domain.UrubuKey, error) {
+	key = strings.TrimSpace(key)
+
+	switch kind {
+	case domain.UrubuKeyRandom:
+		generated, err := uuid.NewV4()
+		if err != nil {
+			return "", err
+		}
+		return domain.UrubuKey(generated.String()), nil
+
+	case domain.UrubuKeyEmail:
+		address, err := mail.ParseAddress(key)
+		if err != nil || address.Address != key {
+			return "", InvalidUrubuKeyErr
+		}
+		return domain.UrubuKey(strings.ToLower(key)), nil
+
+	case domain.UrubuKeyPhone:
+		phone := phoneSeparators.Replace(key)
+		if !phonePattern.MatchString(phone) {
+			return "", InvalidUrubuKeyErr
+		}
+		return domain.UrubuKey(phone), nil
+
+	case domain.UrubuKeyDocument:
+		document := documentMarks.Replace(key)
+		if !validCPF(document) {
+			return "", InvalidUrubuKeyErr
+		}
+		return domain.UrubuKey(document), nil
+	}
+
+	return "", InvalidUrubuKeyErr
+}
+
+// lookupUrubuKey canonicalises a key typed by a payor without knowing its
+// kind, so "Foo@Bar.com" and "123.456.789-09" still resolve.
+func lookupUrubuKey(key string) string {
+	key = strings.TrimSpace(key)
+
+	if strings.Contains(key, "@") {
+		return strings.ToLower(key)
+	}
+	if document := documentMarks.Replace(key); documentPattern.MatchString(document) {
+		return document
+	}
+	if phone := phoneSeparators.Replace(key); phonePattern.MatchString(phone) {
+		return phone
+	}
+
+	return key
+}
+
+func validCPF(document string) bool {
+	if !documentPattern.MatchString(document) {
+		return false
+	}
+
+	if strings.Count(document, document[:1]) == len(document) {
+		return false
+	}
+
+	for _, length := range []int{9, 10} {
+		sum := 0
+		for i := 0; i < length; i++ {
+			sum += int(document[i]-'0') * (length + 1 - i)
+		}
+
+		digit := sum * 10 % 11
+		if digit == 10 {
+			digit = 0
+		}
+
+		if digit != int(document[length]-'0') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// maskName keeps the first name and reduces every other name to its initial,
+// enough for a payor to recognise the payee without exposing the full name.
+func maskName(fullname string) string {
+	names := strings.Fields(fullname)
+	if len(names) == 0 {
+		return ""
+	}
+
+	masked := []string{names[0]}
+	for _, name := range names[1:] {
+		initial, _ := utf8.DecodeRuneInString(name)
+		masked = append(masked, string(unicode.ToUpper(initial))+".")
+	}
+
+	return strings.Join(masked, " ")
+}
+
+func maskAccount(id int) string {
+	account := fmt.Sprintf("%06d", id)
+	return strings.Repeat("*", len(account)-2) + account[len(account)-2:]
+}
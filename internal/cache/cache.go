@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrMiss = errors.New("cache: key not found")
+
+// Store is the expiring key-value store behind sessions, idempotency keys,
+// rate limits and the login cache. Get returns ErrMiss for absent or expired
+// keys.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, key string) error
+	// Incr bumps the counter at key, starting a window-long expiry on the
+	// first hit, and returns the new count with the time left on it.
+	Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+	Ping(ctx context.Context) error
+}
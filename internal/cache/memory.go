@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Memory is an in-process Store for running the API without Redis. Expired
+// keys are dropped lazily when they are next touched.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		entries: map[string]entry{},
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) lookup(key string) (entry, bool) {
+	e, ok := m.entries[key]
+	if ok && e.expired(m.now()) {
+		delete(m.entries, key)
+		return entry{}, false
+	}
+
+	return e, ok
+}
+
+func (m *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return m.now().Add(ttl)
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok {
+		return nil, ErrMiss
+	}
+
+	return append([]byte(nil), e.value...), nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = entry{value: append([]byte(nil), value...), expiresAt: m.expiry(ttl)}
+
+	return nil
+}
+
+func (m *Memory) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+
+	m.entries[key] = entry{value: append([]byte(nil), value...), expiresAt: m.expiry(ttl)}
+
+	return true, nil
+}
+
+func (m *Memory) Del(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+
+	return nil
+}
+
+func (m *Memory) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok {
+		e = entry{value: []byte("0"), expiresAt: m.expiry(window)}
+	}
+
+	count, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	count++
+
+	e.value = []byte(strconv.FormatInt(count, 10))
+	m.entries[key] = e
+
+	return count, e.expiresAt.Sub(m.now()), nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
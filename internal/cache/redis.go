@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type redisStore struct {
+	client *redis.Client
+}
+
+func NewRedis(client *redis.Client) Store {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.WithContext(ctx).Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.WithContext(ctx).Set(key, value, ttl).Err()
+}
+
+func (s *redisStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.client.WithContext(ctx).SetNX(key, value, ttl).Result()
+}
+
+func (s *redisStore) Del(ctx context.Context, key string) error {
+	return s.client.WithContext(ctx).Del(key).Err()
+}
+
+func (s *redisStore) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	client := s.client.WithContext(ctx)
+
+	pipe := client.TxPipeline()
+	incr := pipe.Incr(key)
+	ttl := pipe.TTL(key)
+
+	if _, err := pipe.Exec(); err != nil {
+		return 0, 0, err
+	}
+
+	if ttl.Val() < 0 {
+		if err := client.Expire(key, window).Err(); err != nil {
+			return 0, 0, err
+		}
+		return incr.Val(), window, nil
+	}
+
+	return incr.Val(), ttl.Val(), nil
+}
+
+func (s *redisStore) Ping(ctx context.Context) error {
+	return s.client.WithContext(ctx).Ping().Err()
+}
@@ -0,0 +1,497 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+type Config struct {
+	App         AppConfig         `yaml:"app"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Auth        AuthConfig        `yaml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Scheduler   SchedulerConfig   `yaml:"scheduler"`
+	Log         LogConfig         `yaml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Timeouts    TimeoutConfig     `yaml:"timeouts"`
+}
+
+type AppConfig struct {
+	Port            string        `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	StartupTimeout  time.Duration `yaml:"startup_timeout"`
+}
+
+type DatabaseConfig struct {
+	URL            string `yaml:"url"`
+	MigrateOnStart bool   `yaml:"migrate_on_start"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+const (
+	AuthModeCookie = "cookie"
+	AuthModeBearer = "bearer"
+	AuthModeBoth   = "both"
+)
+
+type AuthConfig struct {
+	Mode         string        `yaml:"mode"`
+	SessionTTL   time.Duration `yaml:"session_ttl"`
+	UserCacheTTL time.Duration `yaml:"user_cache_ttl"`
+	BcryptCost   int           `yaml:"bcrypt_cost"`
+
+	// Bearer tokens, used when Mode is bearer or both.
+	KeysetFile      string        `yaml:"keyset_file"`
+	Issuer          string        `yaml:"issuer"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+}
+
+func (a AuthConfig) CookieEnabled() bool {
+	return a.Mode == AuthModeCookie || a.Mode == AuthModeBoth
+}
+
+func (a AuthConfig) BearerEnabled() bool {
+	return a.Mode == AuthModeBearer || a.Mode == AuthModeBoth
+}
+
+type RateLimitConfig struct {
+	UrubuKeyLookups int           `yaml:"urubukey_lookups"`
+	Window          time.Duration `yaml:"window"`
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+	// PendingTTL is how long a key stays reserved while its request runs,
+	// so a process dying mid-request does not hold the key for the full TTL.
+	PendingTTL time.Duration `yaml:"pending_ttl"`
+}
+
+type SchedulerConfig struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// TimeoutConfig bounds how long each money operation may run, including
+// transaction retries, before it is cancelled.
+type TimeoutConfig struct {
+	Transfer  time.Duration `yaml:"transfer"`
+	Deposit   time.Duration `yaml:"deposit"`
+	Trading   time.Duration `yaml:"trading"`
+	Reversal  time.Duration `yaml:"reversal"`
+	Statement time.Duration `yaml:"statement"`
+	// StatementExport bounds a CSV or OFX download, which keeps its
+	// transaction and connection open until the client has read it all.
+	StatementExport time.Duration `yaml:"statement_export"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+func Default() Config {
+	return Config{
+		App: AppConfig{
+			Port:            "8080",
+			ShutdownTimeout: 30 * time.Second,
+			StartupTimeout:  time.Minute,
+		},
+		Redis: RedisConfig{
+			Addr: "cache:6379",
+		},
+		Auth: AuthConfig{
+			Mode:         AuthModeCookie,
+			SessionTTL:   24 * time.Hour,
+			UserCacheTTL: 72 * time.Hour,
+			BcryptCost:   bcrypt.DefaultCost,
+
+			Issuer:          "urubu_bank",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			UrubuKeyLookups: 10,
+			Window:          time.Minute,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:        24 * time.Hour,
+			PendingTTL: 30 * time.Second,
+		},
+		Scheduler: SchedulerConfig{
+			Interval: time.Minute,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "urubu_bank",
+			SampleRatio: 1,
+		},
+		Timeouts: TimeoutConfig{
+			Transfer:        5 * time.Second,
+			Deposit:         5 * time.Second,
+			Trading:         5 * time.Second,
+			Reversal:        5 * time.Second,
+			Statement:       10 * time.Second,
+			StatementExport: 2 * time.Minute,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file named by
+// CONFIG_FILE (if any), then individual environment variables, and validates
+// the result.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	lookupString("APP_PORT", &c.App.Port)
+	if err := lookupDuration("SHUTDOWN_TIMEOUT", &c.App.ShutdownTimeout); err != nil {
+		return err
+	}
+	if err := lookupDuration("STARTUP_TIMEOUT", &c.App.StartupTimeout); err != nil {
+		return err
+	}
+	lookupString("DATABASE_URL", &c.Database.URL)
+	if err := lookupBool("MIGRATE_ON_START", &c.Database.MigrateOnStart); err != nil {
+		return err
+	}
+	lookupString("REDIS_ADDR", &c.Redis.Addr)
+	lookupString("REDIS_PASSWORD", &c.Redis.Password)
+
+	if err := lookupInt("REDIS_DB", &c.Redis.DB); err != nil {
+		return err
+	}
+	lookupString("AUTH_MODE", &c.Auth.Mode)
+	if err := lookupDuration("SESSION_TTL", &c.Auth.SessionTTL); err != nil {
+		return err
+	}
+	if err := lookupDuration("USER_CACHE_TTL", &c.Auth.UserCacheTTL); err != nil {
+		return err
+	}
+	if err := lookupInt("BCRYPT_COST", &c.Auth.BcryptCost); err != nil {
+		return err
+	}
+	lookupString("AUTH_KEYSET_FILE", &c.Auth.KeysetFile)
+	lookupString("AUTH_ISSUER", &c.Auth.Issuer)
+	if err := lookupDuration("ACCESS_TOKEN_TTL", &c.Auth.AccessTokenTTL); err != nil {
+		return err
+	}
+	if err := lookupDuration("REFRESH_TOKEN_TTL", &c.Auth.RefreshTokenTTL); err != nil {
+		return err
+	}
+	if err := lookupInt("RATE_LIMIT_URUBUKEY_LOOKUPS", &c.RateLimit.UrubuKeyLookups); err != nil {
+		return err
+	}
+	if err := lookupDuration("RATE_LIMIT_WINDOW", &c.RateLimit.Window); err != nil {
+		return err
+	}
+	if err := lookupDuration("IDEMPOTENCY_TTL", &c.Idempotency.TTL); err != nil {
+		return err
+	}
+	if err := lookupDuration("IDEMPOTENCY_PENDING_TTL", &c.Idempotency.PendingTTL); err != nil {
+		return err
+	}
+	if err := lookupDuration("SCHEDULER_INTERVAL", &c.Scheduler.Interval); err != nil {
+		return err
+	}
+	lookupString("LOG_LEVEL", &c.Log.Level)
+	lookupString("LOG_FORMAT", &c.Log.Format)
+	lookupString("TRACING_EXPORTER", &c.Tracing.Exporter)
+	lookupString("OTEL_EXPORTER_OTLP_ENDPOINT", &c.Tracing.Endpoint)
+	lookupString("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+	if err := lookupFloat("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio); err != nil {
+		return err
+	}
+	if err := lookupDuration("TIMEOUT_TRANSFER", &c.Timeouts.Transfer); err != nil {
+		return err
+	}
+	if err := lookupDuration("TIMEOUT_DEPOSIT", &c.Timeouts.Deposit); err != nil {
+		return err
+	}
+	if err := lookupDuration("TIMEOUT_TRADING", &c.Timeouts.Trading); err != nil {
+		return err
+	}
+	if err := lookupDuration("TIMEOUT_REVERSAL", &c.Timeouts.Reversal); err != nil {
+		return err
+	}
+	if err := lookupDuration("TIMEOUT_STATEMENT", &c.Timeouts.Statement); err != nil {
+		return err
+	}
+	if err := lookupDuration("TIMEOUT_STATEMENT_EXPORT", &c.Timeouts.StatementExport); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.App.Port == "" {
+		errs = append(errs, errors.New("app.port is required"))
+	}
+	if c.App.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("app.shutdown_timeout must be positive"))
+	}
+	if c.App.StartupTimeout <= 0 {
+		errs = append(errs, errors.New("app.startup_timeout must be positive"))
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url is required"))
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if c.Redis.DB < 0 {
+		errs = append(errs, errors.New("redis.db must not be negative"))
+	}
+	switch c.Auth.Mode {
+	case AuthModeCookie:
+	case AuthModeBearer, AuthModeBoth:
+		if c.Auth.KeysetFile == "" {
+			errs = append(errs, errors.New("auth.keyset_file is required for bearer tokens"))
+		}
+		if c.Auth.Issuer == "" {
+			errs = append(errs, errors.New("auth.issuer is required for bearer tokens"))
+		}
+		if c.Auth.AccessTokenTTL <= 0 {
+			errs = append(errs, errors.New("auth.access_token_ttl must be positive"))
+		}
+		if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+			errs = append(errs, errors.New("auth.refresh_token_ttl must be longer than auth.access_token_ttl"))
+		}
+	default:
+		errs = append(errs, errors.New("auth.mode must be one of cookie, bearer or both"))
+	}
+	if c.Auth.SessionTTL <= 0 {
+		errs = append(errs, errors.New("auth.session_ttl must be positive"))
+	}
+	if c.Auth.UserCacheTTL <= 0 {
+		errs = append(errs, errors.New("auth.user_cache_ttl must be positive"))
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if c.RateLimit.UrubuKeyLookups <= 0 {
+		errs = append(errs, errors.New("rate_limit.urubukey_lookups must be positive"))
+	}
+	if c.RateLimit.Window <= 0 {
+		errs = append(errs, errors.New("rate_limit.window must be positive"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl must be positive"))
+	}
+	if c.Idempotency.PendingTTL <= 0 {
+		errs = append(errs, errors.New("idempotency.pending_ttl must be positive"))
+	}
+	if c.Scheduler.Interval <= 0 {
+		errs = append(errs, errors.New("scheduler.interval must be positive"))
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, errors.New("log.level must be one of debug, info, warn or error"))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, errors.New("log.format must be json or text"))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required for the otlp exporter"))
+		}
+	default:
+		errs = append(errs, errors.New("tracing.exporter must be one of none, stdout or otlp"))
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.service_name is required"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if c.Timeouts.Transfer <= 0 {
+		errs = append(errs, errors.New("timeouts.transfer must be positive"))
+	}
+	if c.Timeouts.Deposit <= 0 {
+		errs = append(errs, errors.New("timeouts.deposit must be positive"))
+	}
+	if c.Timeouts.Trading <= 0 {
+		errs = append(errs, errors.New("timeouts.trading must be positive"))
+	}
+	if c.Timeouts.Reversal <= 0 {
+		errs = append(errs, errors.New("timeouts.reversal must be positive"))
+	}
+	if c.Timeouts.Statement <= 0 {
+		errs = append(errs, errors.New("timeouts.statement must be positive"))
+	}
+	if c.Timeouts.StatementExport <= 0 {
+		errs = append(errs, errors.New("timeouts.statement_export must be positive"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Redacted returns a copy that is safe to log: passwords are masked and the
+// database URL keeps everything but its password.
+func (c Config) Redacted() Config {
+	c.Database.URL = redactDatabaseURL(c.Database.URL)
+
+	if c.Redis.Password != "" {
+		c.Redis.Password = redacted
+	}
+
+	return c
+}
+
+// dsnPassword matches the password of a keyword DSN, quoted or not.
+var dsnPassword = regexp.MustCompile(`(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+func redactDatabaseURL(databaseURL string) string {
+	if !strings.HasPrefix(databaseURL, "postgres://") && !strings.HasPrefix(databaseURL, "postgresql://") {
+		// lib/pq also accepts keyword DSNs such as "host=db password=s3cret",
+		// which url.Parse happily reads as a path.
+		return dsnPassword.ReplaceAllString(databaseURL, "${1}"+redacted)
+	}
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return redacted
+	}
+
+	if query := u.Query(); query.Has("password") {
+		query.Set("password", "xxxxx")
+		u.RawQuery = query.Encode()
+	}
+
+	return u.Redacted()
+}
+
+func lookupString(name string, target *string) {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		*target = value
+	}
+}
+
+func lookupInt(name string, target *int) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", name, err)
+	}
+
+	*target = parsed
+	return nil
+}
+
+func lookupFloat(name string, target *float64) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", name, err)
+	}
+
+	*target = parsed
+	return nil
+}
+
+func lookupBool(name string, target *bool) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", name, err)
+	}
+
+	*target = parsed
+	return nil
+}
+
+func lookupDuration(name string, target *time.Duration) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", name, err)
+	}
+
+	*target = parsed
+	return nil
+}
@@ -5,6 +5,7 @@ import "time"
 type BankStatemant struct {
 	Balance          BalanceStatement
 	LastTransactions []LastTransaction
+	NextCursor       string `json:"next_cursor,omitempty"`
 }
 
 type LastTransaction struct {
@@ -13,10 +14,25 @@ type LastTransaction struct {
 	Kind         string    `json:"kind"`
 	Description  string    `json:"description"`
 	Payee        string    `json:"payee"`
+	Payor        string    `json:"payor,omitempty"`
 	Completed_at time.Time `json:"completed_at"`
+
+	ReversedTransactionID *int `json:"reversed_transaction_id,omitempty"`
 }
 type BalanceStatement struct {
 	Balance      int       `json:"balance"`
 	Completed_at time.Time `json:"completed_at"`
 	Limit        int       `json:"limit"`
 }
+
+type StatementFilter struct {
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Cursor *StatementCursor
+}
+
+type StatementCursor struct {
+	Completed_at time.Time
+	ID           int
+}
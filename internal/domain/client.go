@@ -1,10 +1,37 @@
 package domain
 
+import "log/slog"
+
 type User struct {
+	ID       int
 	Username string
 	Password string
 }
 
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", u.ID), slog.String("username", u.Username))
+}
+
+const (
+	LoginAccount  = "account"
+	LoginDocument = "document"
+	LoginEmail    = "email"
+)
+
+// Login identifies whose credentials to check: an account number, a CPF or
+// an email, already in the canonical form stored in credentials.
+type Login struct {
+	Kind  string
+	Value string
+}
+
+// Credentials are the identifiers, besides the account number, a costumer
+// logs in with. Empty fields are left as they are on update.
+type Credentials struct {
+	Document string `json:"document,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
 type Costumer struct {
 	ID       int      `json:"id"`
 	Fullname string   `json:"fullname"`
@@ -25,7 +52,14 @@ type CreateCostumer struct {
 	Birth    string `json:"birth"`
 	Limit    int    `json:"limit"`
 	Password string `json:"password"`
+	Document string `json:"document" validate:"required"`
+	Email    string `json:"email" validate:"omitempty,email"`
 }
+
+func (c CreateCostumer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("fullname", c.Fullname), slog.Int("limit", c.Limit))
+}
+
 type CreatedCostumer struct {
 	ID       int      `json:"id"`
 	Fullname string   `json:"fullname"`
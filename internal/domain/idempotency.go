@@ -0,0 +1,9 @@
+package domain
+
+type IdempotentResponse struct {
+	RequestHash string `json:"request_hash"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
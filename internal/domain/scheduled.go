@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+const (
+	RecurrenceOnce    = "once"
+	RecurrenceDaily   = "daily"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+
+	ScheduledActive    = "active"
+	ScheduledCompleted = "completed"
+	ScheduledFailed    = "failed"
+	ScheduledCancelled = "cancelled"
+
+	RunSuccess = "success"
+	RunFailed  = "failed"
+)
+
+type ScheduledTransfer struct {
+	ID            int                    `json:"id"`
+	Client_Id     int                    `json:"client_id"`
+	Value         int                    `json:"value"`
+	Description   string                 `json:"description"`
+	PayeeUrubuKey string                 `json:"payeeurubukey"`
+	NextRunAt     time.Time              `json:"next_run_at"`
+	AnchorAt      time.Time              `json:"-"`
+	Recurrence    string                 `json:"recurrence"`
+	EndAt         *time.Time             `json:"end_at,omitempty"`
+	Status        string                 `json:"status"`
+	Created_at    time.Time              `json:"created_at"`
+	Runs          []ScheduledTransferRun `json:"runs,omitempty"`
+}
+
+type ScheduledTransferRun struct {
+	ID     int       `json:"id"`
+	RunAt  time.Time `json:"run_at"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Next returns when a transfer recurring from at runs again and false once
+// the schedule is exhausted. Monthly runs keep the day of AnchorAt, the first
+// run, falling back to the last day of shorter months.
+func (s ScheduledTransfer) Next(at time.Time) (time.Time, bool) {
+	var next time.Time
+
+	switch s.Recurrence {
+	case RecurrenceDaily:
+		next = at.AddDate(0, 0, 1)
+	case RecurrenceWeekly:
+		next = at.AddDate(0, 0, 7)
+	case RecurrenceMonthly:
+		anchor := s.AnchorAt
+		if anchor.IsZero() {
+			anchor = at
+		}
+		months := (at.Year()-anchor.Year())*12 + int(at.Month()-anchor.Month())
+		next = addMonths(anchor, months+1)
+	default:
+		return time.Time{}, false
+	}
+
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+// addMonths moves t by months, clamping its day to the end of the month
+// instead of overflowing into the next one as time.AddDate does.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"log/slog"
 	"time"
 )
 
@@ -15,6 +16,14 @@ type TransactionDebit struct {
 	Completed_at  time.Time `json:"completed_at"`
 }
 
+func (t TransactionDebit) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("client_id", t.Client_Id),
+		slog.Int("value", t.Value),
+		slog.String("payeeurubukey", t.PayeeUrubuKey),
+	)
+}
+
 type TransactionCredit struct {
 	ID           int       `json:"id"`
 	Client_Id    int       `json:"client_id"`
@@ -38,3 +47,13 @@ type TransactionResponseCredit struct {
 	Newbalance   int       `json:"newbalance"`
 	Completed_at time.Time `json:"completed_at"`
 }
+
+type TransactionReversal struct {
+	ID                    int       `json:"id"`
+	ReversedTransactionID int       `json:"reversed_transaction_id"`
+	Value                 int       `json:"value"`
+	Payor                 string    `json:"payor"`
+	Payee                 string    `json:"payee"`
+	Balance               int       `json:"balance"`
+	Completed_at          time.Time `json:"completed_at"`
+}
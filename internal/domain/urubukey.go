@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+const (
+	UrubuKeyRandom   = "random"
+	UrubuKeyEmail    = "email"
+	UrubuKeyPhone    = "phone"
+	UrubuKeyDocument = "document"
+)
+
+type RegisteredUrubuKey struct {
+	Key        UrubuKey  `json:"key"`
+	Kind       string    `json:"kind"`
+	Created_at time.Time `json:"created_at"`
+}
+
+type UrubuKeyOwner struct {
+	Key     UrubuKey `json:"key"`
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Bank    string   `json:"bank"`
+	Account string   `json:"account"`
+}
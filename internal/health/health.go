@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/migrations"
+)
+
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Checker struct {
+	checks []Check
+}
+
+func New(checks ...Check) *Checker {
+	return &Checker{checks: checks}
+}
+
+func NewChecker(db *sql.DB, store cache.Store, migrator *migrations.Migrator) *Checker {
+	return New(
+		Check{Name: "postgres", Run: db.PingContext},
+		Check{Name: "redis", Run: store.Ping},
+		Check{Name: "migrations", Run: func(ctx context.Context) error {
+			return checkMigrations(ctx, migrator)
+		}},
+	)
+}
+
+// Ready runs every check and returns the failures keyed by check name; an
+// empty map means the service can take traffic.
+func (c *Checker) Ready(ctx context.Context) map[string]error {
+	failures := map[string]error{}
+
+	for _, check := range c.checks {
+		if err := check.Run(ctx); err != nil {
+			failures[check.Name] = err
+		}
+	}
+
+	return failures
+}
+
+func checkMigrations(ctx context.Context, migrator *migrations.Migrator) error {
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A schema ahead of this binary is fine: during a rolling deploy the
+	// first new replica migrates while old ones are still serving.
+	if latest := migrator.Latest(); version < latest {
+		return fmt.Errorf("schema at version %d, expected at least %d", version, latest)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware assigns every request an ID (reusing a valid incoming
+// X-Request-ID), stores it in the request's user context and logs the request
+// once it completes. Errors returned further down the chain are rendered here
+// through the app's ErrorHandler so the logged status is the one sent.
+func Middleware(logger *slog.Logger) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id := ctx.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			generated, err := uuid.NewV4()
+			if err != nil {
+				return err
+			}
+			id = generated.String()
+		}
+
+		ctx.Set(RequestIDHeader, id)
+		ctx.SetUserContext(WithRequestID(ctx.UserContext(), id))
+
+		start := time.Now()
+		if err := ctx.Next(); err != nil {
+			if err := ctx.App().Config().ErrorHandler(ctx, err); err != nil {
+				_ = ctx.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := ctx.Response().StatusCode()
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logger.Log(ctx.UserContext(), level, "request",
+			"method", ctx.Method(),
+			"route", ctx.Route().Path,
+			"status", status,
+			"duration", time.Since(start),
+			"ip", ctx.IP(),
+		)
+
+		return nil
+	}
+}
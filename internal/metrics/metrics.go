@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/apperr"
+	"github.com/go-redis/redis"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "urubu"
+
+const (
+	OperationTransfer = "transfer"
+	OperationDeposit  = "deposit"
+
+	OutcomeSuccess  = "success"
+	OutcomeLimit    = "limit"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+type Metrics struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	operations      *prometheus.CounterVec
+	valueMoved      *prometheus.CounterVec
+	redisDuration   *prometheus.HistogramVec
+}
+
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operations_total",
+			Help:      "Transfers and deposits by outcome.",
+		}, []string{"operation", "outcome"}),
+		valueMoved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "value_moved_total",
+			Help:      "Total value moved by successful operations.",
+		}, []string{"operation"}),
+		redisDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "redis_command_duration_seconds",
+			Help:      "Redis command latency by command.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command"}),
+	}
+
+	m.registry.MustRegister(
+		m.requestDuration,
+		m.operations,
+		m.valueMoved,
+		m.redisDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+	}
+
+	return m
+}
+
+// InstrumentRedis times every command sent through client. A pipeline is
+// one round trip, so it is timed as a whole under the names of its commands,
+// e.g. "pipeline:set,hset,expire".
+func (m *Metrics) InstrumentRedis(client *redis.Client) {
+	client.WrapProcess(func(next func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := next(cmd)
+			m.redisDuration.WithLabelValues(cmd.Name()).Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(next func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := next(cmds)
+			m.redisDuration.WithLabelValues(pipelineName(cmds)).Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+}
+
+func pipelineName(cmds []redis.Cmder) string {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name())
+	}
+
+	return "pipeline:" + strings.Join(names, ",")
+}
+
+func (m *Metrics) Middleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		err := ctx.Next()
+
+		status := ctx.Response().StatusCode()
+		if err != nil {
+			status = apperr.Status(err)
+		}
+
+		m.requestDuration.
+			WithLabelValues(ctx.Method(), ctx.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+func (m *Metrics) Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+func (m *Metrics) RecordOperation(operation, outcome string, value int) {
+	m.operations.WithLabelValues(operation, outcome).Inc()
+
+	if outcome == OutcomeSuccess {
+		m.valueMoved.WithLabelValues(operation).Add(float64(value))
+	}
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS scheduled_transfer_runs;
+DROP TABLE IF EXISTS scheduled_transfers;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS urubu_keys;
+DROP TABLE IF EXISTS clients;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,88 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE  TABLE clients (
+	id SERIAL PRIMARY KEY,
+	fullname TEXT NOT NULL UNIQUE,
+    birth VARCHAR(10) NOT NULL,  
+    password TEXT NOT NULL,
+	credit_limit INTEGER NOT NULL,
+    balance INTEGER DEFAULT 0,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE urubu_keys (
+	id SERIAL PRIMARY KEY,
+	client_id INTEGER NOT NULL,
+	kind VARCHAR(10) NOT NULL CHECK (kind IN ('random', 'email', 'phone', 'document')),
+	key TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_clients_urubu_keys_id
+		FOREIGN KEY (client_id) REFERENCES clients(id)
+);
+
+CREATE INDEX idx_urubu_keys_client_id ON urubu_keys (client_id);
+
+CREATE  TABLE transactions (
+	id SERIAL PRIMARY KEY,
+	client_id INTEGER NOT NULL,
+	value INTEGER NOT NULL,
+	kind CHAR(1) NOT NULL,
+	description VARCHAR(10) NOT NULL,
+	payee TEXT NOT NULL,
+	payor TEXT,
+	transfer_id UUID,
+	reversed_transaction_id INTEGER,
+	completed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_clients_transactions_id
+		FOREIGN KEY (client_id) REFERENCES clients(id),
+	CONSTRAINT fk_transactions_reversed_transaction_id
+		FOREIGN KEY (reversed_transaction_id) REFERENCES transactions(id)
+);
+
+CREATE UNIQUE INDEX idx_transactions_reversed_transaction_id ON transactions (reversed_transaction_id, kind) WHERE reversed_transaction_id IS NOT NULL;
+
+CREATE INDEX idx_transactions_client_id_completed_at ON transactions (client_id, completed_at DESC, id DESC);
+
+CREATE TABLE ledger_entries (
+	id SERIAL PRIMARY KEY,
+	transfer_id UUID NOT NULL,
+	client_id INTEGER,
+	account TEXT NOT NULL,
+	kind CHAR(1) NOT NULL CHECK (kind IN ('d', 'c')),
+	amount INTEGER NOT NULL CHECK (amount > 0),
+	posted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_clients_ledger_entries_id
+		FOREIGN KEY (client_id) REFERENCES clients(id)
+);
+
+CREATE INDEX idx_ledger_entries_client_id ON ledger_entries (client_id);
+CREATE INDEX idx_ledger_entries_transfer_id ON ledger_entries (transfer_id);
+
+CREATE TABLE scheduled_transfers (
+	id SERIAL PRIMARY KEY,
+	client_id INTEGER NOT NULL,
+	value INTEGER NOT NULL CHECK (value > 0),
+	description VARCHAR(10) NOT NULL,
+	payee_urubukey TEXT NOT NULL,
+	next_run_at TIMESTAMP NOT NULL,
+	recurrence VARCHAR(10) NOT NULL CHECK (recurrence IN ('once', 'daily', 'weekly', 'monthly')),
+	end_at TIMESTAMP,
+	status VARCHAR(10) NOT NULL DEFAULT 'active',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	CONSTRAINT fk_clients_scheduled_transfers_id
+		FOREIGN KEY (client_id) REFERENCES clients(id)
+);
+
+CREATE INDEX idx_scheduled_transfers_due ON scheduled_transfers (next_run_at) WHERE status = 'active';
+
+CREATE TABLE scheduled_transfer_runs (
+	id SERIAL PRIMARY KEY,
+	scheduled_transfer_id INTEGER NOT NULL,
+	run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	status VARCHAR(10) NOT NULL,
+	error TEXT,
+	CONSTRAINT fk_scheduled_transfers_runs_id
+		FOREIGN KEY (scheduled_transfer_id) REFERENCES scheduled_transfers(id)
+);
+
+CREATE INDEX idx_fullname_trgm ON clients USING gin (fullname gin_trgm_ops);
@@ -0,0 +1,8 @@
+ALTER TABLE clients ADD COLUMN password TEXT;
+UPDATE clients c SET password = cr.password FROM credentials cr WHERE cr.client_id = c.id;
+ALTER TABLE clients ALTER COLUMN password SET NOT NULL;
+
+-- Fails while two clients share a name; rename one of them first.
+ALTER TABLE clients ADD CONSTRAINT clients_fullname_key UNIQUE (fullname);
+
+DROP TABLE credentials;
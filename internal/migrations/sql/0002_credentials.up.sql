@@ -0,0 +1,16 @@
+CREATE TABLE credentials (
+	client_id INTEGER PRIMARY KEY REFERENCES clients(id) ON DELETE CASCADE,
+	document VARCHAR(11) UNIQUE CHECK (document ~ '^[0-9]{11}$'),
+	email TEXT UNIQUE,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Existing clients keep logging in by account number. Their document and
+-- email urubukeys were never verified, so they are not trusted as logins;
+-- clients add them through PUT /costumers/:id/credentials.
+INSERT INTO credentials (client_id, password)
+SELECT id, password FROM clients;
+
+ALTER TABLE clients DROP COLUMN password;
+ALTER TABLE clients DROP CONSTRAINT clients_fullname_key;
@@ -0,0 +1,10 @@
+-- Written in the same transaction as the transfer it marks, so a due run
+-- whose outcome was never recorded is found here instead of paid twice.
+CREATE TABLE scheduled_transfer_executions (
+	scheduled_transfer_id INTEGER NOT NULL,
+	due_at TIMESTAMP NOT NULL,
+	executed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (scheduled_transfer_id, due_at),
+	CONSTRAINT fk_scheduled_transfers_executions_id
+		FOREIGN KEY (scheduled_transfer_id) REFERENCES scheduled_transfers(id)
+);
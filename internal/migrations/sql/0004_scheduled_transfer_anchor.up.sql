@@ -0,0 +1,6 @@
+-- Recurring transfers are computed from the run_at the costumer chose, so a
+-- monthly transfer on the 31st stays on the last day of shorter months
+-- instead of drifting. Existing transfers are anchored on their next run.
+ALTER TABLE scheduled_transfers ADD COLUMN anchor_at TIMESTAMP;
+UPDATE scheduled_transfers SET anchor_at = next_run_at;
+ALTER TABLE scheduled_transfers ALTER COLUMN anchor_at SET NOT NULL;
@@ -0,0 +1,74 @@
+package testutil
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	RedisAddrEnv     = "TEST_REDIS_ADDR"
+	defaultRedisAddr = "localhost:6379"
+
+	// Database 0 holds the leases; tests get one of the others.
+	redisDatabases = 16
+	leaseTTL       = 10 * time.Minute
+	leasePrefix    = "testutil:lease:"
+)
+
+// Redis returns a client on a Redis database leased to t alone and flushed
+// on both ends. Leases live in database 0, so test binaries running in
+// parallel never share a database.
+func Redis(t testing.TB) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv(RedisAddrEnv)
+	if addr == "" {
+		addr = defaultRedisAddr
+	}
+
+	leases := redis.NewClient(&redis.Options{Addr: addr, DialTimeout: connectTimeout})
+	t.Cleanup(func() { leases.Close() })
+
+	if err := leases.Ping().Err(); err != nil {
+		t.Skipf("redis unavailable at %s (set %s): %v", addr, RedisAddrEnv, err)
+	}
+
+	owner := newID(t)
+
+	for db := 1; db < redisDatabases; db++ {
+		lease := leasePrefix + strconv.Itoa(db)
+
+		leased, err := leases.SetNX(lease, owner, leaseTTL).Result()
+		if err != nil {
+			t.Fatalf("leasing redis database: %v", err)
+		}
+		if !leased {
+			continue
+		}
+
+		client := redis.NewClient(&redis.Options{Addr: addr, DB: db})
+		if err := client.FlushDB().Err(); err != nil {
+			t.Fatalf("flushing redis database %d: %v", db, err)
+		}
+
+		t.Cleanup(func() {
+			if err := client.FlushDB().Err(); err != nil {
+				t.Errorf("flushing redis database %d: %v", db, err)
+			}
+			client.Close()
+
+			if current, _ := leases.Get(lease).Result(); current == owner {
+				leases.Del(lease)
+			}
+		})
+
+		return client
+	}
+
+	t.Fatalf("no free redis database among %d", redisDatabases-1)
+	return nil
+}
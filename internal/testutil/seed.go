@@ -0,0 +1,97 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/FelipeMCassiano/urubu_bank/internal/bank"
+	"github.com/FelipeMCassiano/urubu_bank/internal/cache"
+	"github.com/FelipeMCassiano/urubu_bank/internal/config"
+	"github.com/FelipeMCassiano/urubu_bank/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config is config.Default with the cheapest bcrypt cost, so seeding and
+// logging in stay fast.
+func Config() config.Config {
+	cfg := config.Default()
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+
+	return cfg
+}
+
+// Repository wires the Postgres repository to db, usually from Postgres,
+// and a fresh Redis database owned by t.
+func Repository(t testing.TB, db *sql.DB, cfg config.Config) bank.Respository {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return bank.NewRepository(db, cache.NewRedis(Redis(t)), cfg, logger)
+}
+
+// Seed opens an account with a random urubukey for each costumer, as
+// POST /costumers/create does. Costumers without a document get one from CPF.
+func Seed(t testing.TB, repository bank.Respository, costumers ...domain.CreateCostumer) []domain.CreatedCostumer {
+	t.Helper()
+
+	ctx := context.Background()
+	created := make([]domain.CreatedCostumer, 0, len(costumers))
+
+	for _, costumer := range costumers {
+		if costumer.Document == "" {
+			costumer.Document = CPF()
+		}
+
+		account, err := repository.CreateNewAccount(ctx, costumer)
+		if err != nil {
+			t.Fatalf("seeding %s: %v", costumer.Fullname, err)
+		}
+
+		account.UrubuKey, err = repository.GenerateUrubuKey(ctx, account.ID)
+		if err != nil {
+			t.Fatalf("seeding urubukey for %s: %v", costumer.Fullname, err)
+		}
+
+		created = append(created, account)
+	}
+
+	return created
+}
+
+// CPF returns a random document with valid check digits.
+func CPF() string {
+	digits := make([]int, 9, 11)
+	for i := range digits {
+		digits[i] = rand.Intn(10)
+	}
+	// All-equal digits pass the checksum but are rejected as documents.
+	if digits[0] == digits[1] {
+		digits[1] = (digits[1] + 1) % 10
+	}
+
+	for _, length := range []int{9, 10} {
+		sum := 0
+		for i := 0; i < length; i++ {
+			sum += digits[i] * (length + 1 - i)
+		}
+
+		digit := sum * 10 % 11
+		if digit == 10 {
+			digit = 0
+		}
+		digits = append(digits, digit)
+	}
+
+	document := ""
+	for _, digit := range digits {
+		document += strconv.Itoa(digit)
+	}
+
+	return document
+}